@@ -0,0 +1,879 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/evanwiseman/gator/internal/database"
+	"github.com/evanwiseman/gator/internal/rss"
+	"github.com/evanwiseman/gator/internal/schedule"
+	"github.com/google/uuid"
+)
+
+// Limits the number of concurrent requests made to a single host
+type hostLimiter struct {
+	mu    sync.Mutex
+	limit int
+	slots map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{
+		limit: limit,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// Block until a slot for the host is free and return a func to release it
+func (h *hostLimiter) acquire(context context.Context, host string) (func(), error) {
+	h.mu.Lock()
+	slot, ok := h.slots[host]
+	if !ok {
+		slot = make(chan struct{}, h.limit)
+		h.slots[host] = slot
+	}
+	h.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-context.Done():
+		return nil, context.Err()
+	}
+}
+
+// Default bounds of the agg schedule, also used by fetch. defaultOnceInterval is the
+// shortest time between fetches of a feed when agg --once is run without a time duration.
+const (
+	defaultMaxInterval  = 24 * time.Hour
+	defaultMaxBackoff   = 24 * time.Hour
+	defaultOnceInterval = time.Minute
+)
+
+// Counts of what a scrape did with the items of a feed, skipped items were unchanged.
+// statusCode and bytes describe the response, they are zero when there was none.
+type scrapeStats struct {
+	items       int
+	inserted    int
+	updated     int
+	skipped     int
+	notModified bool
+	statusCode  int
+	bytes       int64
+}
+
+// Totals over every feed scraped by an aggregator
+type aggSummary struct {
+	mu          sync.Mutex
+	feeds       int
+	notModified int
+	failed      int
+	inserted    int
+	updated     int
+	skipped     int
+}
+
+func (sum *aggSummary) add(stats scrapeStats, err error) {
+	sum.mu.Lock()
+	defer sum.mu.Unlock()
+	sum.feeds++
+	if err != nil {
+		sum.failed++
+	}
+	if stats.notModified {
+		sum.notModified++
+	}
+	sum.inserted += stats.inserted
+	sum.updated += stats.updated
+	sum.skipped += stats.skipped
+}
+
+// Aggregator fans feed fetches out over a pool of workers
+type aggregator struct {
+	s       *State
+	policy  schedule.Policy
+	hosts   *hostLimiter
+	summary aggSummary
+}
+
+// Longest a scrape can spend fetching, every attempt timing out plus the waits between
+// retries. Claims are leased well beyond that to leave room for waiting on a per-host slot
+// and storing the posts.
+const (
+	maxFetchTime = (fetchRetries+1)*rss.FetchTimeout + (1<<fetchRetries-1)*fetchRetryDelay
+	claimLease   = 4 * maxFetchTime
+)
+
+// Claim the feed that has been due the longest. The row is locked with SKIP LOCKED and
+// its next fetch pushed back past the lease in the same statement, so workers in this or
+// any other agg process never claim the same feed while it is being fetched. The fetch
+// reschedules the feed once it completes.
+func (a *aggregator) claimFeed(context context.Context) (database.Feed, error) {
+	now := time.Now()
+	return a.s.DB.ClaimNextFeed(context, database.ClaimNextFeedParams{
+		LeaseUntil: now.Add(max(a.policy.MinInterval, claimLease)),
+		DueAt:      now,
+	})
+}
+
+// Claim and scrape one feed for every job received until jobs is closed or shutdown
+// begins. Claims and scrapes run on work so they can outlive the shutdown signal.
+func (a *aggregator) work(shutdown, work context.Context, jobs <-chan struct{}) {
+	for range jobs {
+		// Don't start on a new feed once shutdown has begun
+		if shutdown.Err() != nil {
+			return
+		}
+		a.scrapeNext(work)
+	}
+}
+
+// Scrape due feeds until none are left or shutdown begins, used by agg --once
+func (a *aggregator) drain(shutdown, work context.Context) {
+	for shutdown.Err() == nil && a.scrapeNext(work) {
+	}
+}
+
+// Claim and scrape the next due feed, false is returned when there was nothing to claim
+func (a *aggregator) scrapeNext(context context.Context) bool {
+	feed, err := a.claimFeed(context)
+	if errors.Is(err, sql.ErrNoRows) { // No feed is due yet
+		return false
+	} else if err != nil {
+		fmt.Printf("unable to claim next feed to fetch: %v\n", err)
+		return false
+	}
+
+	stats, err := scrapeFeed(context, a.s, a.hosts, feed, a.policy)
+	a.summary.add(stats, err)
+	return true
+}
+
+// Number of times a fetch that failed for a temporary reason is retried, and the delay
+// before the first retry which doubles on every attempt
+const (
+	fetchRetries    = 2
+	fetchRetryDelay = 5 * time.Second
+)
+
+// Fetch a feed and store its posts, recording the outcome on the feed. The error of the
+// last attempt is returned.
+func scrapeFeed(context context.Context, s *State, hosts *hostLimiter, feed database.Feed, policy schedule.Policy) (scrapeStats, error) {
+	// Retry server and network errors, a missing feed or one that can't be parsed fails
+	// the same way every time so it is recorded straight away. Servers that are rate
+	// limiting us or ask us to come back later with Retry-After are left to the backoff.
+	feed, stats, fetchErr := attemptFetch(context, s, hosts, feed, policy)
+	delay := fetchRetryDelay
+	for attempt := 1; attempt <= fetchRetries && retryNow(fetchErr); attempt++ {
+		fmt.Printf("unable to fetch '%v', retrying in %v: %v\n", feed.Name.String, delay, fetchErr)
+		select {
+		case <-time.After(delay):
+		case <-context.Done():
+		}
+		if context.Err() != nil {
+			break
+		}
+		feed, stats, fetchErr = attemptFetch(context, s, hosts, feed, policy)
+		delay *= 2
+	}
+
+	// Record the outcome on the feed so broken feeds show up in `feeds`
+	var err error
+	if context.Err() != nil {
+		// Cancelled during shutdown, this is not the feed's fault so leave it as is
+		fmt.Printf("fetch of '%v' cancelled: %v\n", feed.Name.String, context.Err())
+		return stats, context.Err()
+	} else if fetchErr != nil {
+		fmt.Printf("unable to scrape '%v': %v\n", feed.Name.String, fetchErr)
+		var failures int32
+		failures, err = s.DB.MarkFeedFailed(context, database.MarkFeedFailedParams{
+			ID:        feed.ID,
+			LastError: sql.NullString{String: fetchErr.Error(), Valid: true},
+		})
+		var disabled bool
+		if err == nil {
+			disabled, err = disableDeadFeed(context, s, feed, fetchErr, failures)
+		}
+		if err == nil && !disabled {
+			err = backOffFeed(context, s, feed, policy, fetchErr, failures)
+		}
+	} else {
+		err = s.DB.MarkFeedSucceeded(context, feed.ID)
+	}
+	if err != nil {
+		fmt.Printf("unable to record fetch result for '%v': %v\n", feed.Name.String, err)
+	}
+	return stats, fetchErr
+}
+
+// Whether a failed fetch is worth retrying straight away rather than at its next backoff
+func retryNow(err error) bool {
+	return rss.IsTemporary(err) && !rss.IsRateLimited(err) && rss.RetryAfter(err) == 0
+}
+
+// Fetch a feed once and add the attempt to its fetch history. Attempts cut short by
+// shutdown are left out of the history. The per-host slot is only held for the attempt,
+// not while waiting to retry. The feed is returned as it is after the fetch, which is a
+// different feed when it moved and was merged into one that already had its new url.
+func attemptFetch(context context.Context, s *State, hosts *hostLimiter, feed database.Feed, policy schedule.Policy) (database.Feed, scrapeStats, error) {
+	host := feed.Url.String
+	if u, err := url.Parse(feed.Url.String); err == nil {
+		host = u.Host
+	}
+	release, err := hosts.acquire(context, host)
+	if err != nil {
+		return feed, scrapeStats{}, err
+	}
+	defer release()
+
+	startedAt := time.Now()
+	feed, stats, fetchErr := fetchFeedPosts(context, s, feed, policy)
+	if context.Err() != nil {
+		return feed, stats, fetchErr
+	}
+	observeFetch(startedAt, stats, fetchErr)
+
+	var errorMessage sql.NullString
+	if fetchErr != nil {
+		errorMessage = sql.NullString{String: fetchErr.Error(), Valid: true}
+	}
+	err = s.DB.CreateFeedFetch(context, database.CreateFeedFetchParams{
+		ID:         uuid.New(),
+		FeedID:     feed.ID,
+		StartedAt:  startedAt,
+		DurationMs: int32(time.Since(startedAt).Milliseconds()),
+		StatusCode: sql.NullInt32{Int32: int32(stats.statusCode), Valid: stats.statusCode != 0},
+		Bytes:      sql.NullInt64{Int64: stats.bytes, Valid: stats.bytes > 0},
+		Items:      int32(stats.items),
+		Inserted:   int32(stats.inserted),
+		Error:      errorMessage,
+	})
+	if err != nil {
+		fmt.Printf("unable to record fetch of '%v' in its history: %v\n", feed.Name.String, err)
+	}
+	return feed, stats, fetchErr
+}
+
+// Push the next attempt of a failing feed out exponentially, the backoff resets once a
+// fetch succeeds and the feed is scheduled normally again
+func backOffFeed(context context.Context, s *State, feed database.Feed, policy schedule.Policy, fetchErr error, failures int32) error {
+	delay := policy.Backoff(int(failures), rss.RetryAfter(fetchErr))
+	err := s.DB.SetFeedSchedule(context, database.SetFeedScheduleParams{
+		ID:                   feed.ID,
+		NextFetchAt:          sql.NullTime{Time: schedule.Next(time.Now().Add(delay), storedHints(feed)), Valid: true},
+		FetchIntervalSeconds: feed.FetchIntervalSeconds,
+		SkipHours:            feed.SkipHours,
+		SkipDays:             feed.SkipDays,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%v: failed %v time(s) in a row, next attempt in %v\n", feed.Name.String, failures, delay.Round(time.Second))
+	return nil
+}
+
+// Stop scheduling a feed that is gone or has failed too many times in a row, it stays
+// disabled until re-enabled with `feed reenable`
+func disableDeadFeed(context context.Context, s *State, feed database.Feed, fetchErr error, failures int32) (bool, error) {
+	var reason string
+	if errors.Is(fetchErr, rss.ErrGone) {
+		reason = "feed is gone"
+	} else if limit := s.Cfg.GetDisableAfterFailures(); limit > 0 && int(failures) >= limit {
+		reason = fmt.Sprintf("failed %v times in a row", failures)
+	} else {
+		return false, nil
+	}
+
+	err := s.DB.DisableFeed(context, database.DisableFeedParams{
+		ID:             feed.ID,
+		DisabledReason: sql.NullString{String: reason, Valid: true},
+	})
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("%v: disabled, %v\n", feed.Name.String, reason)
+	return true, nil
+}
+
+// Fetch a feed, insert its items as posts and schedule its next fetch. The feed the posts
+// were stored under is returned, see moveFeed.
+func fetchFeedPosts(context context.Context, s *State, feed database.Feed, policy schedule.Policy) (database.Feed, scrapeStats, error) {
+	var stats scrapeStats
+	result, err := rss.FetchFeed(context, feed.Url.String, rss.FetchOptions{
+		ETag:         feed.Etag.String,
+		LastModified: feed.LastModified.String,
+		MaxBodySize:  s.Cfg.MaxFeedSize,
+	})
+	var httpErr *rss.HTTPError
+	if errors.As(err, &httpErr) {
+		stats.statusCode = httpErr.StatusCode
+	}
+	if err != nil {
+		return feed, stats, err
+	}
+	stats.statusCode = result.StatusCode
+	stats.bytes = result.Bytes
+
+	// Stop following redirects once the feed has moved for good
+	if movedTo := movedURL(feed.Url.String, result); movedTo != feed.Url.String {
+		feed, err = moveFeed(context, s, feed, movedTo)
+		if err != nil {
+			return feed, stats, fmt.Errorf("unable to move feed to '%v': %v", movedTo, err)
+		}
+	}
+
+	// The feed has not changed since the last fetch so there is nothing to insert, keep
+	// fetching it as often as before and around the hours it asked to skip last time
+	if result.NotModified {
+		fmt.Printf("%v: not modified\n", feed.Name.String)
+		stats.notModified = true
+		interval := policy.MaxInterval
+		if feed.FetchIntervalSeconds.Valid {
+			interval = policy.Clamp(time.Duration(feed.FetchIntervalSeconds.Int32) * time.Second)
+		}
+		return feed, stats, scheduleFeed(context, s, feed, interval, storedHints(feed))
+	}
+
+	// Remember the site the feed belongs to, used as the htmlUrl in OPML exports
+	siteURL := result.Feed.Channel.Link
+	if siteURL != feed.SiteUrl.String {
+		err = s.DB.UpdateFeedSiteURL(context, database.UpdateFeedSiteURLParams{
+			ID:      feed.ID,
+			SiteUrl: sql.NullString{String: siteURL, Valid: siteURL != ""},
+		})
+		if err != nil {
+			return feed, stats, fmt.Errorf("unable to update site url: %v", err)
+		}
+	}
+
+	var insertErr error
+	var published []time.Time
+	failed := 0
+	stats.items = len(result.Feed.Channel.Item)
+	for _, i := range result.Feed.Channel.Item {
+		// Items without a usable publish date are still stored, just without the date
+		var publishedAt sql.NullTime
+		if i.PubDate != "" {
+			t, err := rss.ParseRSSTime(i.PubDate)
+			if err != nil {
+				fmt.Printf("unable to parse rss item pub date %v: %v\n", i.PubDate, err)
+				parseFailuresTotal.Inc("pub_date")
+			} else {
+				publishedAt = sql.NullTime{Time: t, Valid: true}
+				published = append(published, t)
+			}
+		}
+
+		change, err := storePost(context, s, feed, i, publishedAt)
+		if err != nil {
+			failed++
+			insertErr = err
+			continue
+		}
+		switch change {
+		case postInserted:
+			stats.inserted++
+		case postUpdated:
+			stats.updated++
+		default:
+			stats.skipped++
+		}
+	}
+
+	// Print a single line per feed since workers output concurrently
+	fmt.Printf(
+		"%v: %v item(s), %v new post(s), %v updated, %v skipped\n",
+		feed.Name.String,
+		stats.items,
+		stats.inserted,
+		stats.updated,
+		stats.skipped,
+	)
+	if insertErr != nil {
+		return feed, stats, fmt.Errorf("unable to store %v of %v posts: %v", failed, stats.items, insertErr)
+	}
+
+	// Only save the cache headers once every item is stored, otherwise the next fetch would
+	// get a 304 and the items that failed would not be retried until the feed changes
+	err = s.DB.UpdateFeedCacheHeaders(context, database.UpdateFeedCacheHeadersParams{
+		ID:           feed.ID,
+		Etag:         sql.NullString{String: result.ETag, Valid: result.ETag != ""},
+		LastModified: sql.NullString{String: result.LastModified, Valid: result.LastModified != ""},
+	})
+	if err != nil {
+		return feed, stats, fmt.Errorf("unable to update cache headers: %v", err)
+	}
+
+	channel := result.Feed.Channel
+	hints := schedule.ParseHints(channel.TTL, channel.UpdatePeriod, channel.UpdateFrequency, channel.SkipHours, channel.SkipDays)
+	interval := policy.Interval(schedule.PostingInterval(published), hints)
+	return feed, stats, scheduleFeed(context, s, feed, interval, hints)
+}
+
+// Set when the feed is due next, pushed out of any hours or days the feed asks to skip.
+// The skipped hours and days are stored with the schedule for fetches that don't return
+// the feed.
+func scheduleFeed(context context.Context, s *State, feed database.Feed, interval time.Duration, hints schedule.Hints) error {
+	next := schedule.Next(time.Now().Add(interval), hints)
+	var skipHours, skipDays []int32
+	for _, hour := range hints.SkipHours {
+		skipHours = append(skipHours, int32(hour))
+	}
+	for _, day := range hints.SkipDays {
+		skipDays = append(skipDays, int32(day))
+	}
+	err := s.DB.SetFeedSchedule(context, database.SetFeedScheduleParams{
+		ID:                   feed.ID,
+		NextFetchAt:          sql.NullTime{Time: next, Valid: true},
+		FetchIntervalSeconds: sql.NullInt32{Int32: int32(interval.Seconds()), Valid: true},
+		SkipHours:            skipHours,
+		SkipDays:             skipDays,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to schedule next fetch: %v", err)
+	}
+	return nil
+}
+
+// Hints of the skipped hours and days stored with a feed's schedule
+func storedHints(feed database.Feed) schedule.Hints {
+	var hints schedule.Hints
+	for _, hour := range feed.SkipHours {
+		hints.SkipHours = append(hints.SkipHours, int(hour))
+	}
+	for _, day := range feed.SkipDays {
+		hints.SkipDays = append(hints.SkipDays, time.Weekday(day))
+	}
+	return hints
+}
+
+// Point a feed at the url it permanently moved to. When another feed already has that url
+// the follows and posts are merged into it and the old feed is deleted, the feed the posts
+// now belong to is returned.
+func moveFeed(context context.Context, s *State, feed database.Feed, newURL string) (database.Feed, error) {
+	tx, err := s.Conn.BeginTx(context, nil)
+	if err != nil {
+		return feed, err
+	}
+	defer tx.Rollback()
+	queries := s.DB.WithTx(tx)
+
+	target, err := queries.GetFeed(context, sql.NullString{String: newURL, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = queries.UpdateFeedURL(context, database.UpdateFeedURLParams{
+			ID:  feed.ID,
+			Url: sql.NullString{String: newURL, Valid: true},
+		})
+		if err != nil {
+			return feed, err
+		}
+		if err = tx.Commit(); err != nil {
+			return feed, err
+		}
+		fmt.Printf("%v: moved from '%v' to '%v'\n", feed.Name.String, feed.Url.String, newURL)
+		feed.Url = sql.NullString{String: newURL, Valid: true}
+		return feed, nil
+	} else if err != nil {
+		return feed, err
+	} else if target.ID == feed.ID { // Already moved by an earlier attempt
+		return target, nil
+	}
+
+	// Follows and posts the target already has are dropped along with the old feed
+	err = queries.MoveFeedFollows(context, database.MoveFeedFollowsParams{
+		ToFeedID:   target.ID,
+		FromFeedID: feed.ID,
+	})
+	if err != nil {
+		return feed, fmt.Errorf("unable to move follows: %v", err)
+	}
+	err = queries.MovePosts(context, database.MovePostsParams{
+		ToFeedID:   target.ID,
+		FromFeedID: feed.ID,
+	})
+	if err != nil {
+		return feed, fmt.Errorf("unable to move posts: %v", err)
+	}
+	err = queries.DeleteFeed(context, feed.ID)
+	if err != nil {
+		return feed, fmt.Errorf("unable to delete old feed: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return feed, err
+	}
+	fmt.Printf("%v: moved to '%v', merged into '%v'\n", feed.Name.String, newURL, target.Name.String)
+	return target, nil
+}
+
+// Outcome of storing a feed item as a post
+type postChange int
+
+const (
+	postUnchanged postChange = iota
+	postInserted
+	postUpdated
+)
+
+// Upsert an item as a post of the feed. Posts are deduplicated per feed by the item's
+// identifier and only rewritten when their content hash changes, every new version is
+// kept as a revision.
+func storePost(context context.Context, s *State, feed database.Feed, item rss.RSSItem, publishedAt sql.NullTime) (postChange, error) {
+	guid := item.Identifier()
+	feedID := uuid.NullUUID{UUID: feed.ID, Valid: true}
+
+	// Posts stored before guids were tracked are keyed by their url, move them over to the
+	// item's identifier so they are updated below instead of inserted again
+	if item.Link != "" {
+		err := s.DB.RekeyLegacyPost(context, database.RekeyLegacyPostParams{
+			Guid:   guid,
+			FeedID: feedID,
+			Url:    item.Link,
+		})
+		if err != nil {
+			return postUnchanged, fmt.Errorf("unable to re-key '%v': %v", item.Title, err)
+		}
+	}
+
+	// A post hashed in an older format is rewritten to store the new hash, but that is not
+	// an edit by the publisher so it keeps its updated_at and gets no revision
+	existing, err := s.DB.GetPostContentHash(context, database.GetPostContentHashParams{
+		FeedID: feedID,
+		Guid:   guid,
+	})
+	formatChanged := false
+	if err == nil {
+		formatChanged = !rss.IsCurrentContentHash(existing.ContentHash.String)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return postUnchanged, fmt.Errorf("unable to get stored version of '%v': %v", item.Title, err)
+	}
+
+	now := time.Now()
+	id := uuid.New()
+	hash := item.ContentHash()
+	params := database.UpsertPostParams{
+		ID:          id,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Title:       sql.NullString{String: item.Title, Valid: true},
+		Url:         sql.NullString{String: item.Link, Valid: item.Link != ""},
+		Description: sql.NullString{String: item.Description, Valid: true},
+		PublishedAt: publishedAt,
+		FeedID:      feedID,
+		Guid:        guid,
+		ContentHash: sql.NullString{String: hash, Valid: true},
+		Content:     sql.NullString{String: item.Content, Valid: item.Content != ""},
+		Author:      sql.NullString{String: item.Author, Valid: item.Author != ""},
+	}
+
+	// Posts only keep the first enclosure, all of them are queued in the enclosures table
+	if len(item.Enclosures) > 0 {
+		enclosure := item.Enclosures[0]
+		params.EnclosureUrl = sql.NullString{String: enclosure.URL, Valid: enclosure.URL != ""}
+		params.EnclosureType = sql.NullString{String: enclosure.Type, Valid: enclosure.Type != ""}
+		params.EnclosureLength = parseEnclosureLength(enclosure.Length)
+	}
+	if formatChanged {
+		params.UpdatedAt = existing.UpdatedAt
+	}
+
+	post, err := s.DB.UpsertPost(context, params)
+	if errors.Is(err, sql.ErrNoRows) { // Already stored with the same content
+		return postUnchanged, nil
+	} else if err != nil {
+		return postUnchanged, err
+	}
+
+	if !formatChanged {
+		err = s.DB.CreatePostRevision(context, database.CreatePostRevisionParams{
+			ID:          uuid.New(),
+			CreatedAt:   now,
+			PostID:      post.ID,
+			Title:       post.Title,
+			Description: post.Description,
+			ContentHash: hash,
+		})
+		if err != nil {
+			return postUnchanged, fmt.Errorf("unable to record revision of '%v': %v", item.Title, err)
+		}
+	}
+
+	// Replace the categories of the post with the ones from the latest version
+	err = s.DB.DeletePostCategories(context, post.ID)
+	if err != nil {
+		return postUnchanged, fmt.Errorf("unable to clear categories of '%v': %v", item.Title, err)
+	}
+	for _, category := range item.Categories {
+		if category == "" {
+			continue
+		}
+		err = s.DB.CreatePostCategory(context, database.CreatePostCategoryParams{
+			PostID: post.ID,
+			Name:   category,
+		})
+		if err != nil {
+			return postUnchanged, fmt.Errorf("unable to add category '%v' to '%v': %v", category, item.Title, err)
+		}
+	}
+
+	err = storeEnclosures(context, s, post.ID, item)
+	if err != nil {
+		return postUnchanged, err
+	}
+
+	// An updated post keeps the id it was first inserted with
+	if post.ID == id {
+		return postInserted, nil
+	} else if formatChanged {
+		return postUnchanged, nil
+	}
+	return postUpdated, nil
+}
+
+// Parse the length attribute of an enclosure, which publishers often leave empty or zero
+func parseEnclosureLength(value string) sql.NullInt64 {
+	length, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	return sql.NullInt64{Int64: length, Valid: err == nil && length > 0}
+}
+
+// Save every enclosure of an item along with its podcast details for the download command
+func storeEnclosures(context context.Context, s *State, postID uuid.UUID, item rss.RSSItem) error {
+	var duration, episode, season sql.NullInt32
+	if d, err := rss.ParseITunesDuration(item.Duration); err == nil {
+		duration = sql.NullInt32{Int32: int32(d.Seconds()), Valid: true}
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(item.Episode)); err == nil {
+		episode = sql.NullInt32{Int32: int32(n), Valid: true}
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(item.Season)); err == nil {
+		season = sql.NullInt32{Int32: int32(n), Valid: true}
+	}
+
+	for _, enclosure := range item.Enclosures {
+		if enclosure.URL == "" {
+			continue
+		}
+		err := s.DB.UpsertEnclosure(context, database.UpsertEnclosureParams{
+			ID:              uuid.New(),
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+			PostID:          postID,
+			Url:             enclosure.URL,
+			Type:            sql.NullString{String: enclosure.Type, Valid: enclosure.Type != ""},
+			Length:          parseEnclosureLength(enclosure.Length),
+			DurationSeconds: duration,
+			Episode:         episode,
+			Season:          season,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to save enclosure '%v' of '%v': %v", enclosure.URL, item.Title, err)
+		}
+	}
+	return nil
+}
+
+func HandlerAgg(s *State, cmd Command) error {
+	// Validate Args
+	usage := "usage: agg [--once] [--metrics <addr>] [--workers <n>] [--per-host <n>] [--max-interval <duration>] [--max-backoff <duration>] [--shutdown-timeout <duration>] [time_duration]"
+	flags := flag.NewFlagSet("agg", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	once := flags.Bool("once", false, "fetch every due feed once and exit")
+	metricsAddr := flags.String("metrics", "", "address to serve prometheus metrics on")
+	workers := flags.Int("workers", 1, "number of feeds fetched in parallel")
+	perHost := flags.Int("per-host", 2, "maximum concurrent requests to a single host")
+	maxInterval := flags.Duration("max-interval", defaultMaxInterval, "longest time between fetches of a feed")
+	maxBackoff := flags.Duration("max-backoff", defaultMaxBackoff, "longest wait before retrying a failing feed")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 30*time.Second, "time given to in-flight fetches on shutdown")
+	err := flags.Parse(cmd.Args)
+	if err != nil {
+		return fmt.Errorf("%v. %v", err, usage)
+	}
+	if flags.NArg() < 1 && !*once {
+		return fmt.Errorf("missing time duration, it can only be left out with --once. %v", usage)
+	} else if flags.NArg() > 1 {
+		return fmt.Errorf("too many arguments. %v", usage)
+	}
+	if *workers <= 0 {
+		return fmt.Errorf("workers cannot be <= 0")
+	}
+	if *perHost <= 0 {
+		return fmt.Errorf("per-host cannot be <= 0")
+	}
+
+	// A single pass has no ticks, the time duration only bounds how soon feeds are due again
+	timeBetweenRequests := defaultOnceInterval
+	if flags.NArg() == 1 {
+		timeBetweenRequests, err = time.ParseDuration(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("unable to parse time duration: %v", err)
+		}
+		if timeBetweenRequests <= 0 {
+			return fmt.Errorf("time duration cannot be <= 0")
+		}
+	}
+	if *maxInterval < timeBetweenRequests {
+		return fmt.Errorf("max-interval cannot be shorter than the time duration")
+	}
+	if *maxBackoff < timeBetweenRequests {
+		return fmt.Errorf("max-backoff cannot be shorter than the time duration")
+	}
+
+	agg := &aggregator{
+		s: s,
+		policy: schedule.Policy{
+			MinInterval: timeBetweenRequests,
+			MaxInterval: *maxInterval,
+			MaxBackoff:  *maxBackoff,
+		},
+		hosts: newHostLimiter(*perHost),
+	}
+
+	// Serve metrics at /metrics for as long as agg runs
+	if *metricsAddr != "" {
+		listener, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			return fmt.Errorf("unable to listen for metrics on '%v': %v", *metricsAddr, err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", newAggRegistry(s, *maxInterval).Handler())
+		server := &http.Server{Handler: mux}
+		go func() {
+			err := server.Serve(listener)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Printf("metrics server stopped: %v\n", err)
+			}
+		}()
+		defer server.Close()
+		fmt.Printf("serving metrics on http://%v/metrics\n", listener.Addr())
+	}
+
+	// Stop claiming feeds on SIGINT/SIGTERM
+	shutdown, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// In-flight fetches keep running after a signal until the shutdown timeout passes
+	work, cancelWork := context.WithCancel(context.WithoutCancel(shutdown))
+	defer cancelWork()
+
+	// Every worker claims at most one feed per tick, which bounds in-flight requests to the
+	// number of workers. Ticks are dropped while every worker is still busy. With --once
+	// workers instead claim feeds back to back until none are due.
+	var wg sync.WaitGroup
+	jobs := make(chan struct{}, *workers)
+	for range *workers {
+		wg.Go(func() {
+			if *once {
+				agg.drain(shutdown, work)
+			} else {
+				agg.work(shutdown, work, jobs)
+			}
+		})
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	if *once {
+		select {
+		case <-done:
+		case <-shutdown.Done():
+		}
+	} else {
+		ticker := time.NewTicker(timeBetweenRequests)
+		defer ticker.Stop()
+		for running := true; running; {
+			for range *workers {
+				select {
+				case jobs <- struct{}{}:
+				default:
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-shutdown.Done():
+				running = false
+			}
+		}
+	}
+	close(jobs)
+
+	if shutdown.Err() != nil {
+		// Restore the default signal handling so a second signal exits right away
+		stop()
+		fmt.Printf("shutting down, waiting up to %v for in-flight fetches, signal again to exit now\n", *shutdownTimeout)
+		select {
+		case <-done:
+		case <-time.After(*shutdownTimeout):
+			fmt.Println("shutdown timeout reached, cancelling in-flight fetches")
+			cancelWork()
+			<-done
+		}
+	}
+
+	if *once {
+		sum := &agg.summary
+		fmt.Printf(
+			"fetched %v feed(s), %v not modified, %v failed: %v new post(s), %v updated, %v skipped\n",
+			sum.feeds,
+			sum.notModified,
+			sum.failed,
+			sum.inserted,
+			sum.updated,
+			sum.skipped,
+		)
+	}
+	fmt.Println("aggregator stopped")
+	return nil
+}
+
+// Scheduling used by fetch, which has no time duration to take the minimum interval from
+var fetchPolicy = schedule.Policy{
+	MinInterval: defaultOnceInterval,
+	MaxInterval: defaultMaxInterval,
+	MaxBackoff:  defaultMaxBackoff,
+}
+
+func HandlerFetch(s *State, cmd Command) error {
+	// Validate Args
+	usage := "usage: fetch <url>"
+	if len(cmd.Args) < 1 {
+		return fmt.Errorf("missing url. %v", usage)
+	} else if len(cmd.Args) > 1 {
+		return fmt.Errorf("more than one url provided. %v", usage)
+	}
+
+	context := context.Background()
+	feed, err := s.DB.GetFeed(context, sql.NullString{String: cmd.Args[0], Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no feed with url '%v', add it with addfeed first", cmd.Args[0])
+	} else if err != nil {
+		return fmt.Errorf("unable to get feed from '%v': %v", cmd.Args[0], err)
+	}
+	if feed.DisabledAt.Valid {
+		fmt.Printf("'%v' is disabled (%v), agg won't fetch it until it is re-enabled\n", feed.Name.String, feed.DisabledReason.String)
+	}
+
+	// Scrape through the same pipeline as agg, which prints the item counts and records
+	// the result on the feed
+	_, err = scrapeFeed(context, s, newHostLimiter(1), feed, fetchPolicy)
+	if err != nil {
+		return fmt.Errorf("unable to fetch '%v': %v", feed.Name.String, err)
+	}
+	return nil
+}
@@ -3,19 +3,25 @@ package cli
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/evanwiseman/gator/internal/config"
 	"github.com/evanwiseman/gator/internal/database"
 	"github.com/evanwiseman/gator/internal/rss"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type State struct {
-	DB  *database.Queries
-	Cfg *config.Config
+	DB   *database.Queries
+	Conn *sql.DB // used to run queries in a transaction
+	Cfg  *config.Config
 }
 
 type Command struct {
@@ -140,75 +146,29 @@ func HandlerUsers(s *State, cmd Command) error {
 	return nil
 }
 
-func scrapeFeed(s *State) {
-	context := context.Background()
-	feed, err := s.DB.GetNextFeedToFetch(context)
-
-	if err != nil {
-		return
-	}
-
-	err = s.DB.MarkFeedFetched(context, feed.ID)
-	if err != nil {
-		return
-	}
-
-	rssFeed, err := rss.FetchFeed(context, feed.Url.String)
-	if err != nil {
-		return
-	}
-	fmt.Printf("%v:\n", feed.Name.String)
-	for _, i := range rssFeed.Channel.Item {
-		fmt.Printf("* %v\n", i.Title)
-		t, err := rss.ParseRSSTime(i.PubDate)
-		if err != nil {
-			fmt.Printf("unable to parse rss item pub date %v: %v", i.PubDate, err)
-			continue
-		}
-
-		_, err = s.DB.CreatePost(context, database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-			Title:       sql.NullString{String: i.Title, Valid: true},
-			Url:         sql.NullString{String: i.Link, Valid: true},
-			Description: sql.NullString{String: i.Description, Valid: true},
-			PublishedAt: sql.NullTime{Time: t, Valid: true},
-			FeedID:      uuid.NullUUID{UUID: feed.ID, Valid: true},
-		})
-		if err != nil { // URL likely already exists in table
-			continue
-		}
-	}
+// Report whether err is a postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
 }
 
-func HandlerAgg(s *State, cmd Command) error {
+func HandlerBrowse(s *State, cmd Command, user database.User) error {
 	// Validate Args
-	usage := "usage: agg <time_duration>"
-	if len(cmd.Args) < 1 {
-		return fmt.Errorf("missing time duration. %v", usage)
-	} else if len(cmd.Args) > 1 {
-		return fmt.Errorf("too many arguments. %v", usage)
-	}
-
-	timeBetweenRequests, err := time.ParseDuration(cmd.Args[0])
+	usage := "usage: browse [--category <name>] [--full] [limit(int)]"
+	flags := flag.NewFlagSet("browse", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	category := flags.String("category", "", "only show posts in this category")
+	full := flags.Bool("full", false, "show the full content of posts instead of their description")
+	err := flags.Parse(cmd.Args)
 	if err != nil {
-		return fmt.Errorf("unable to parse time duration: %v", err)
-	}
-
-	ticker := time.NewTicker(timeBetweenRequests)
-	for ; ; <-ticker.C {
-		scrapeFeed(s)
+		return fmt.Errorf("%v. %v", err, usage)
 	}
-}
 
-func HandlerBrowse(s *State, cmd Command, user database.User) error {
 	var limit int32
-	usage := "usage: [limit(int)]"
-	if len(cmd.Args) > 1 {
+	if flags.NArg() > 1 {
 		return fmt.Errorf("too many arguments. %v", usage)
-	} else if len(cmd.Args) == 1 {
-		parsedLimit, err := strconv.Atoi(cmd.Args[0])
+	} else if flags.NArg() == 1 {
+		parsedLimit, err := strconv.Atoi(flags.Arg(0))
 		limit = int32(parsedLimit)
 		if err != nil {
 			return fmt.Errorf("limit is not an integer")
@@ -223,8 +183,9 @@ func HandlerBrowse(s *State, cmd Command, user database.User) error {
 	context := context.Background()
 
 	posts, err := s.DB.GetPostsForUser(context, database.GetPostsForUserParams{
-		ID:    user.ID,
-		Limit: limit,
+		ID:       user.ID,
+		Category: sql.NullString{String: *category, Valid: *category != ""},
+		Limit:    limit,
 	})
 	if err != nil {
 		return fmt.Errorf("unable to get posts from user '%v': %v", user.Name.String, err)
@@ -232,32 +193,131 @@ func HandlerBrowse(s *State, cmd Command, user database.User) error {
 
 	for _, post := range posts {
 		if post.Title.Valid {
-			fmt.Printf("Title: %v\n", post.Title.String)
+			fmt.Printf("Title: %v", post.Title.String)
+			if post.UpdatedSinceSeen {
+				fmt.Print(" (updated since you saw it)")
+			}
+			fmt.Print("\n")
+		}
+		if post.Author.Valid {
+			fmt.Printf("Author: %v\n", post.Author.String)
 		}
 		if post.PublishedAt.Valid {
 			fmt.Printf("Published At: %v\n", post.PublishedAt.Time)
 		}
-		if post.Description.Valid {
+
+		categories, err := s.DB.GetPostCategories(context, post.ID)
+		if err != nil {
+			return fmt.Errorf("unable to get categories of '%v': %v", post.Title.String, err)
+		}
+		if len(categories) > 0 {
+			fmt.Printf("Categories: %v\n", strings.Join(categories, ", "))
+		}
+
+		if post.EnclosureUrl.Valid {
+			fmt.Printf("Enclosure: %v", post.EnclosureUrl.String)
+			if post.EnclosureType.Valid {
+				fmt.Printf(" (%v)", post.EnclosureType.String)
+			}
+			if post.EnclosureLength.Valid {
+				fmt.Printf(" %v bytes", post.EnclosureLength.Int64)
+			}
+			fmt.Print("\n")
+		}
+
+		if *full && post.Content.Valid {
+			fmt.Printf("%v\n\n", post.Content.String)
+		} else if post.Description.Valid {
 			fmt.Printf("%v\n\n", post.Description.String)
 		}
+
+		// Remember when the post was shown so later edits can be flagged
+		err = s.DB.MarkPostViewed(context, database.MarkPostViewedParams{
+			UserID:   user.ID,
+			PostID:   post.ID,
+			ViewedAt: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to mark post '%v' as viewed: %v", post.Title.String, err)
+		}
 	}
 
 	return nil
 }
 
+// Resolve a URL given by the user to the feed it points to and fetch it to make sure it
+// is a working feed. HTML pages are searched for the feeds they advertise, a single feed
+// is used directly while several are listed for the user to choose from.
+func resolveFeed(context context.Context, s *State, rawURL string) (string, *rss.RSSFeed, error) {
+	opts := rss.FetchOptions{MaxBodySize: s.Cfg.MaxFeedSize}
+	result, err := rss.FetchFeed(context, rawURL, opts)
+	var pageErr *rss.HTMLPageError
+	if err == nil {
+		return movedURL(rawURL, result), result.Feed, nil
+	} else if !errors.As(err, &pageErr) {
+		return "", nil, fmt.Errorf("'%v' is not a working feed: %v", rawURL, err)
+	}
+
+	switch len(pageErr.Feeds) {
+	case 0:
+		return "", nil, fmt.Errorf("'%v' is an html page that does not advertise any feeds", rawURL)
+	case 1:
+		feedURL := pageErr.Feeds[0].URL
+		fmt.Printf("found feed %v on '%v'\n", feedURL, rawURL)
+		result, err = rss.FetchFeed(context, feedURL, opts)
+		if err != nil {
+			return "", nil, fmt.Errorf("'%v' is not a working feed: %v", feedURL, err)
+		}
+		return movedURL(feedURL, result), result.Feed, nil
+	default:
+		var b strings.Builder
+		fmt.Fprintf(&b, "'%v' advertises several feeds, run the command again with one of them:", rawURL)
+		for _, feed := range pageErr.Feeds {
+			fmt.Fprintf(&b, "\n  * %v", feed.URL)
+			if feed.Title != "" {
+				fmt.Fprintf(&b, " (%v)", feed.Title)
+			}
+		}
+		return "", nil, errors.New(b.String())
+	}
+}
+
+// Get the url a feed has permanently moved to, or the url it was fetched from
+func movedURL(feedURL string, result *rss.FetchResult) string {
+	if result.PermanentRedirect {
+		return result.FinalURL
+	}
+	return feedURL
+}
+
 func HandlerAddFeed(s *State, cmd Command, user database.User) error {
 	// Validate Args
-	usage := "usage: addfeed <name> <url>"
-	if len(cmd.Args) < 2 {
+	usage := "usage: addfeed [name] <url>"
+	if len(cmd.Args) < 1 {
 		return fmt.Errorf("missing argument(s). %v", usage)
 	} else if len(cmd.Args) > 2 {
 		return fmt.Errorf("too many argument(s). %v", usage)
 	}
 
-	// Fetch the current user
 	context := context.Background()
-	name := cmd.Args[0]
-	url := cmd.Args[1]
+	var name string
+	if len(cmd.Args) == 2 {
+		name = cmd.Args[0]
+	}
+
+	// Fetch the feed before storing it so broken urls are rejected up front
+	url, rssFeed, err := resolveFeed(context, s, cmd.Args[len(cmd.Args)-1])
+	if err != nil {
+		return err
+	}
+
+	// Default the name to the title of the feed
+	if name == "" {
+		name = strings.TrimSpace(rssFeed.Channel.Title)
+	}
+	if name == "" {
+		name = url
+	}
 
 	// Attempt to create a feed
 	feed, err := s.DB.CreateFeed(context, database.CreateFeedParams{
@@ -267,6 +327,7 @@ func HandlerAddFeed(s *State, cmd Command, user database.User) error {
 		Name:      sql.NullString{String: name, Valid: true},
 		Url:       sql.NullString{String: url, Valid: true},
 		UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+		SiteUrl:   sql.NullString{String: rssFeed.Channel.Link, Valid: rssFeed.Channel.Link != ""},
 	})
 	if err != nil {
 		return fmt.Errorf("unable to add entry to feed: %v", err)
@@ -303,9 +364,24 @@ func HandlerFeeds(s *State, cmd Command) error {
 		return fmt.Errorf("error unable to get feeds: %v", err)
 	}
 
-	// Output the feeds
+	// Output the feeds along with the health of their last fetch
 	for _, feed := range feeds {
-		fmt.Printf("* '%v' (%v) - %v\n", feed.Name.String, feed.Url.String, feed.UserName.String)
+		health := "ok"
+		if feed.DisabledAt.Valid {
+			health = fmt.Sprintf(
+				"disabled at %v: %v",
+				feed.DisabledAt.Time.Format(time.RFC3339),
+				feed.DisabledReason.String,
+			)
+		} else if feed.FailureCount > 0 {
+			health = fmt.Sprintf(
+				"failing %v time(s), last at %v: %v",
+				feed.FailureCount,
+				feed.LastErrorAt.Time.Format(time.RFC3339),
+				feed.LastError.String,
+			)
+		}
+		fmt.Printf("* '%v' (%v) - %v [%v]\n", feed.Name.String, feed.Url.String, feed.UserName.String, health)
 	}
 
 	return nil
@@ -323,8 +399,18 @@ func HandlerFollow(s *State, cmd Command, user database.User) error {
 	context := context.Background()
 	feedURL := cmd.Args[0]
 
-	// Fetch the feed
+	// Fetch the feed, the url may be a page advertising a feed already in the database
 	feed, err := s.DB.GetFeed(context, sql.NullString{String: feedURL, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		resolvedURL, _, resolveErr := resolveFeed(context, s, feedURL)
+		if resolveErr != nil {
+			return resolveErr
+		}
+		if resolvedURL != feedURL {
+			feedURL = resolvedURL
+			feed, err = s.DB.GetFeed(context, sql.NullString{String: feedURL, Valid: true})
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("unable to get feed from '%v': %v", feedURL, err)
 	}
@@ -389,7 +475,50 @@ func HandlerFollowing(s *State, cmd Command, user database.User) error {
 
 	// Output to console
 	for _, feedFollow := range feedFollows {
-		fmt.Printf("* %v\n", feedFollow.FeedName.String)
+		fmt.Printf("* %v", feedFollow.FeedName.String)
+		if feedFollow.Folder.Valid {
+			fmt.Printf(" [%v]", feedFollow.Folder.String)
+		}
+		fmt.Print("\n")
+	}
+	return nil
+}
+
+func HandlerFolder(s *State, cmd Command, user database.User) error {
+	// Validate Args
+	usage := "usage: folder <url> [name]"
+	if len(cmd.Args) < 1 {
+		return fmt.Errorf("missing url. %v", usage)
+	} else if len(cmd.Args) > 2 {
+		return fmt.Errorf("too many arguments. %v", usage)
+	}
+
+	context := context.Background()
+	url := cmd.Args[0]
+
+	// Without a name the feed is taken out of its folder
+	var folder sql.NullString
+	if len(cmd.Args) == 2 {
+		folder = sql.NullString{String: strings.Trim(cmd.Args[1], "/"), Valid: true}
+	}
+
+	updated, err := s.DB.SetFeedFollowFolder(context, database.SetFeedFollowFolderParams{
+		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Url:    sql.NullString{String: url, Valid: true},
+		Folder: folder,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set folder of '%v': %v", url, err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("'%v' is not followed by '%v'", url, user.Name.String)
+	}
+
+	// Output to console
+	if folder.Valid {
+		fmt.Printf("moved '%v' to folder '%v'\n", url, folder.String)
+	} else {
+		fmt.Printf("removed '%v' from its folder\n", url)
 	}
 	return nil
 }
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/evanwiseman/gator/internal/database"
+	"github.com/evanwiseman/gator/internal/podcast"
+	"github.com/google/uuid"
+)
+
+func HandlerDownload(s *State, cmd Command, user database.User) error {
+	// Validate Args
+	usage := "usage: download [--dir <path>] [--list] [limit(int)]"
+	flags := flag.NewFlagSet("download", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dir := flags.String("dir", "", "directory to download episodes to, overrides download_dir in the config")
+	list := flags.Bool("list", false, "list the queued episodes without downloading them")
+	err := flags.Parse(cmd.Args)
+	if err != nil {
+		return fmt.Errorf("%v. %v", err, usage)
+	}
+
+	var limit int32
+	if flags.NArg() > 1 {
+		return fmt.Errorf("too many arguments. %v", usage)
+	} else if flags.NArg() == 1 {
+		parsedLimit, err := strconv.Atoi(flags.Arg(0))
+		limit = int32(parsedLimit)
+		if err != nil {
+			return fmt.Errorf("limit is not an integer")
+		}
+		if limit <= 0 {
+			return fmt.Errorf("limit cannot be <= 0")
+		}
+	} else {
+		limit = 10
+	}
+
+	downloadDir := *dir
+	if downloadDir == "" {
+		downloadDir, err = s.Cfg.GetDownloadDir()
+		if err != nil {
+			return fmt.Errorf("unable to get download directory: %v", err)
+		}
+	}
+
+	context := context.Background()
+
+	// Fetch the enclosures of followed feeds that have not been downloaded yet
+	enclosures, err := s.DB.GetPendingEnclosuresForUser(context, database.GetPendingEnclosuresForUserParams{
+		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Limit:  limit,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to get queued episodes for '%v': %v", user.Name.String, err)
+	}
+	if len(enclosures) == 0 {
+		fmt.Println("no episodes to download")
+		return nil
+	}
+
+	downloaded := 0
+	for _, enclosure := range enclosures {
+		fmt.Printf("* %v - %v", enclosure.FeedName.String, enclosure.PostTitle.String)
+		if enclosure.Season.Valid || enclosure.Episode.Valid {
+			fmt.Printf(" (S%vE%v)", enclosure.Season.Int32, enclosure.Episode.Int32)
+		}
+		if enclosure.DurationSeconds.Valid {
+			fmt.Printf(" [%v]", time.Duration(enclosure.DurationSeconds.Int32)*time.Second)
+		}
+		fmt.Print("\n")
+		if *list {
+			continue
+		}
+
+		filePath := filepath.Join(
+			downloadDir,
+			podcast.SanitizeName(enclosure.FeedName.String),
+			podcast.FileName(enclosure.PostTitle.String, enclosure.Url, enclosure.Type.String),
+		)
+		size, err := podcast.Download(context, enclosure.Url, filePath)
+		if err != nil {
+			// Leave the episode queued so the download resumes on the next run
+			fmt.Printf("  unable to download: %v\n", err)
+			continue
+		}
+
+		err = s.DB.MarkEnclosureDownloaded(context, database.MarkEnclosureDownloadedParams{
+			ID:       enclosure.ID,
+			FilePath: sql.NullString{String: filePath, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("unable to mark '%v' as downloaded: %v", enclosure.Url, err)
+		}
+		fmt.Printf("  saved %v bytes to %v\n", size, filePath)
+		downloaded++
+	}
+
+	if !*list {
+		fmt.Printf("downloaded %v of %v episode(s)\n", downloaded, len(enclosures))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/evanwiseman/gator/internal/database"
+	"github.com/evanwiseman/gator/internal/schedule"
+	"github.com/google/uuid"
+)
+
+func HandlerFeed(s *State, cmd Command) error {
+	// Validate Args
+	usage := "usage: feed <disabled | reenable <url> [new_url] | stats <url> [n]>"
+	if len(cmd.Args) < 1 {
+		return fmt.Errorf("missing subcommand. %v", usage)
+	}
+
+	switch cmd.Args[0] {
+	case "disabled":
+		if len(cmd.Args) > 1 {
+			return fmt.Errorf("too many arguments. %v", usage)
+		}
+		return listDisabledFeeds(s)
+	case "reenable":
+		if len(cmd.Args) < 2 {
+			return fmt.Errorf("missing url. %v", usage)
+		} else if len(cmd.Args) > 3 {
+			return fmt.Errorf("too many arguments. %v", usage)
+		}
+		var newURL string
+		if len(cmd.Args) == 3 {
+			newURL = cmd.Args[2]
+		}
+		return reenableFeed(s, cmd.Args[1], newURL)
+	case "stats":
+		if len(cmd.Args) < 2 {
+			return fmt.Errorf("missing url. %v", usage)
+		} else if len(cmd.Args) > 3 {
+			return fmt.Errorf("too many arguments. %v", usage)
+		}
+		limit := int32(10)
+		if len(cmd.Args) == 3 {
+			n, err := strconv.Atoi(cmd.Args[2])
+			if err != nil {
+				return fmt.Errorf("n is not an integer")
+			}
+			if n <= 0 {
+				return fmt.Errorf("n cannot be <= 0")
+			}
+			limit = int32(n)
+		}
+		return printFeedStats(s, cmd.Args[1], limit)
+	default:
+		return fmt.Errorf("unknown subcommand '%v'. %v", cmd.Args[0], usage)
+	}
+}
+
+// Output the feeds agg has stopped fetching along with why
+func listDisabledFeeds(s *State) error {
+	context := context.Background()
+	feeds, err := s.DB.GetDisabledFeeds(context)
+	if err != nil {
+		return fmt.Errorf("unable to get disabled feeds: %v", err)
+	}
+	if len(feeds) == 0 {
+		fmt.Println("no feeds are disabled")
+		return nil
+	}
+
+	for _, feed := range feeds {
+		fmt.Printf(
+			"* '%v' (%v) disabled at %v: %v\n",
+			feed.Name.String,
+			feed.Url.String,
+			feed.DisabledAt.Time.Format(time.RFC3339),
+			feed.DisabledReason.String,
+		)
+		if feed.LastError.Valid {
+			fmt.Printf("    last error: %v\n", feed.LastError.String)
+		}
+	}
+	return nil
+}
+
+// Put a disabled feed back in the fetch queue, optionally at a corrected url
+func reenableFeed(s *State, feedURL, newURL string) error {
+	context := context.Background()
+	rows, err := s.DB.ReenableFeed(context, database.ReenableFeedParams{
+		NewUrl: sql.NullString{String: newURL, Valid: newURL != ""},
+		Url:    sql.NullString{String: feedURL, Valid: true},
+	})
+	if isUniqueViolation(err) {
+		return fmt.Errorf("a feed with url '%v' already exists", newURL)
+	} else if err != nil {
+		return fmt.Errorf("unable to re-enable '%v': %v", feedURL, err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("no disabled feed with url '%v'", feedURL)
+	}
+
+	if newURL != "" {
+		fmt.Printf("re-enabled '%v' at '%v'\n", feedURL, newURL)
+	} else {
+		fmt.Printf("re-enabled '%v'\n", feedURL)
+	}
+	return nil
+}
+
+// Number of recent posts the posting cadence is estimated from
+const cadenceSample = 20
+
+// Output the fetch history of a feed, summarized and followed by the last attempts
+func printFeedStats(s *State, feedURL string, limit int32) error {
+	context := context.Background()
+	feed, err := s.DB.GetFeed(context, sql.NullString{String: feedURL, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no feed with url '%v'", feedURL)
+	} else if err != nil {
+		return fmt.Errorf("unable to get feed from '%v': %v", feedURL, err)
+	}
+
+	stats, err := s.DB.GetFeedFetchStats(context, feed.ID)
+	if err != nil {
+		return fmt.Errorf("unable to get fetch stats of '%v': %v", feedURL, err)
+	}
+	fetches, err := s.DB.GetFeedFetches(context, database.GetFeedFetchesParams{
+		FeedID: feed.ID,
+		Limit:  limit,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to get fetch history of '%v': %v", feedURL, err)
+	}
+	publishTimes, err := s.DB.GetRecentPublishTimes(context, database.GetRecentPublishTimesParams{
+		FeedID: uuid.NullUUID{UUID: feed.ID, Valid: true},
+		Limit:  cadenceSample,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to get posts of '%v': %v", feedURL, err)
+	}
+
+	fmt.Printf("'%v' (%v)\n", feed.Name.String, feed.Url.String)
+	if stats.Attempts == 0 {
+		fmt.Println("  not fetched yet")
+	} else {
+		fmt.Printf(
+			"  %v attempt(s), %.1f%% successful, %v average latency\n",
+			stats.Attempts,
+			float64(stats.Successes)/float64(stats.Attempts)*100,
+			time.Duration(stats.AverageDurationMs*float64(time.Millisecond)).Round(time.Millisecond),
+		)
+	}
+
+	var published []time.Time
+	for _, t := range publishTimes {
+		published = append(published, t.Time)
+	}
+	if cadence := schedule.PostingInterval(published); cadence > 0 {
+		fmt.Printf("  posts about every %v (last %v post(s))\n", cadence.Round(time.Minute), len(published))
+	} else {
+		fmt.Println("  posting cadence unknown, not enough posts")
+	}
+
+	if feed.DisabledAt.Valid {
+		fmt.Printf("  disabled at %v: %v\n", feed.DisabledAt.Time.Format(time.RFC3339), feed.DisabledReason.String)
+	} else if feed.NextFetchAt.Valid {
+		fmt.Printf("  next fetch at %v\n", feed.NextFetchAt.Time.Format(time.RFC3339))
+	}
+
+	if len(fetches) > 0 {
+		fmt.Printf("  last %v attempt(s):\n", len(fetches))
+	}
+	for _, fetch := range fetches {
+		status := "no response"
+		if fetch.StatusCode.Valid {
+			status = strconv.Itoa(int(fetch.StatusCode.Int32))
+		}
+		fmt.Printf(
+			"  * %v %v %vms %v byte(s), %v item(s), %v new",
+			fetch.StartedAt.Format(time.RFC3339),
+			status,
+			fetch.DurationMs,
+			fetch.Bytes.Int64,
+			fetch.Items,
+			fetch.Inserted,
+		)
+		if fetch.Error.Valid {
+			fmt.Printf(" - error: %v", fetch.Error.String)
+		}
+		fmt.Println()
+	}
+	return nil
+}
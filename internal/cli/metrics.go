@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/evanwiseman/gator/internal/metrics"
+	"github.com/evanwiseman/gator/internal/rss"
+)
+
+// Aggregator metrics, exposed by agg --metrics
+var (
+	fetchesTotal = metrics.NewCounterVec(
+		"gator_feed_fetches_total",
+		"Feed fetch attempts by outcome.",
+		"outcome",
+	)
+	postsInsertedTotal = metrics.NewCounterVec(
+		"gator_posts_inserted_total",
+		"Posts inserted from fetched feeds.",
+	)
+	parseFailuresTotal = metrics.NewCounterVec(
+		"gator_parse_failures_total",
+		"Feeds that could not be parsed and items with a publish date that could not be parsed.",
+		"kind",
+	)
+	fetchDuration = metrics.NewHistogram(
+		"gator_fetch_duration_seconds",
+		"Time taken to fetch a feed and store its posts.",
+		0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30,
+	)
+	fetchBodyBytes = metrics.NewHistogram(
+		"gator_fetch_body_bytes",
+		"Size of fetched feed bodies.",
+		1<<10, 10<<10, 100<<10, 1<<20, 10<<20,
+	)
+)
+
+// Record a fetch attempt in the aggregator metrics
+func observeFetch(startedAt time.Time, stats scrapeStats, err error) {
+	fetchesTotal.Inc(fetchOutcome(stats, err))
+	fetchDuration.Observe(time.Since(startedAt).Seconds())
+	if stats.bytes > 0 {
+		fetchBodyBytes.Observe(float64(stats.bytes))
+	}
+	if stats.inserted > 0 {
+		postsInsertedTotal.Add(float64(stats.inserted))
+	}
+	if errors.Is(err, rss.ErrUnparsable) {
+		parseFailuresTotal.Inc("feed")
+	}
+}
+
+// Classify the result of a fetch for the fetches counter
+func fetchOutcome(stats scrapeStats, err error) string {
+	var httpErr *rss.HTTPError
+	var netErr net.Error
+	switch {
+	case err == nil && stats.notModified:
+		return "not_modified"
+	case err == nil:
+		return "success"
+	case errors.As(err, &httpErr):
+		return "http_error"
+	case errors.Is(err, rss.ErrUnparsable):
+		return "parse_error"
+	case errors.As(err, &netErr):
+		return "network_error"
+	default:
+		return "error"
+	}
+}
+
+// Create a registry with the aggregator metrics and a gauge of the enabled feeds that
+// haven't been fetched within maxInterval
+func newAggRegistry(s *State, maxInterval time.Duration) *metrics.Registry {
+	overdueFeeds := metrics.NewGaugeFunc(
+		"gator_overdue_feeds",
+		"Enabled feeds not fetched within the maximum interval.",
+		func() (float64, error) {
+			// The cutoff is computed by the database since last_fetched_at is in its time zone
+			count, err := s.DB.CountOverdueFeeds(context.Background(), maxInterval.Seconds())
+			return float64(count), err
+		},
+	)
+
+	registry := metrics.NewRegistry()
+	registry.Register(
+		fetchesTotal,
+		postsInsertedTotal,
+		parseFailuresTotal,
+		fetchDuration,
+		fetchBodyBytes,
+		overdueFeeds,
+	)
+	return registry
+}
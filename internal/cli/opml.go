@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/evanwiseman/gator/internal/database"
+	"github.com/evanwiseman/gator/internal/opml"
+	"github.com/google/uuid"
+)
+
+// Report whether a string is an absolute http(s) URL
+func isFeedURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func HandlerImport(s *State, cmd Command, user database.User) error {
+	// Validate Args
+	usage := "usage: import <file.opml>"
+	if len(cmd.Args) < 1 {
+		return fmt.Errorf("missing opml file. %v", usage)
+	} else if len(cmd.Args) > 1 {
+		return fmt.Errorf("more than one file provided. %v", usage)
+	}
+
+	// Read the subscriptions from the OPML file
+	file, err := os.Open(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("unable to open '%v': %v", cmd.Args[0], err)
+	}
+	defer file.Close()
+
+	doc, err := opml.Parse(file)
+	if err != nil {
+		return fmt.Errorf("unable to parse '%v': %v", cmd.Args[0], err)
+	}
+
+	context := context.Background()
+	created, existing, invalid, followed := 0, 0, 0, 0
+	for _, sub := range doc.Subscriptions() {
+		if !isFeedURL(sub.XMLURL) {
+			fmt.Printf("* invalid: '%v' has no valid feed url (%v)\n", sub.Title, sub.XMLURL)
+			invalid++
+			continue
+		}
+
+		name := sub.Title
+		if name == "" {
+			name = sub.XMLURL
+		}
+
+		// Reuse the feed when it is already in the database
+		feed, err := s.DB.GetFeed(context, sql.NullString{String: sub.XMLURL, Valid: true})
+		if errors.Is(err, sql.ErrNoRows) {
+			feed, err = s.DB.CreateFeed(context, database.CreateFeedParams{
+				ID:        uuid.New(),
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+				Name:      sql.NullString{String: name, Valid: true},
+				Url:       sql.NullString{String: sub.XMLURL, Valid: true},
+				UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+				SiteUrl:   sql.NullString{String: sub.HTMLURL, Valid: sub.HTMLURL != ""},
+			})
+			if err != nil {
+				return fmt.Errorf("unable to add feed '%v': %v", sub.XMLURL, err)
+			}
+			fmt.Printf("* created: '%v' (%v)\n", name, sub.XMLURL)
+			created++
+		} else if err != nil {
+			return fmt.Errorf("unable to get feed '%v': %v", sub.XMLURL, err)
+		} else {
+			fmt.Printf("* already exists: '%v' (%v)\n", feed.Name.String, sub.XMLURL)
+			existing++
+		}
+
+		// Attempt to follow the feed, it is fine if the user already does
+		_, err = s.DB.CreateFeedFollow(context, database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+			FeedID:    uuid.NullUUID{UUID: feed.ID, Valid: true},
+		})
+		if err == nil {
+			followed++
+		} else if !isUniqueViolation(err) {
+			return fmt.Errorf("unable to follow '%v': %v", sub.XMLURL, err)
+		}
+
+		// Keep the folder the feed was filed under in the OPML file
+		if sub.Folder != "" {
+			_, err = s.DB.SetFeedFollowFolder(context, database.SetFeedFollowFolderParams{
+				UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+				Url:    sql.NullString{String: sub.XMLURL, Valid: true},
+				Folder: sql.NullString{String: sub.Folder, Valid: true},
+			})
+			if err != nil {
+				return fmt.Errorf("unable to file '%v' under '%v': %v", sub.XMLURL, sub.Folder, err)
+			}
+		}
+	}
+
+	// Output summary to console
+	fmt.Printf(
+		"processed %v outline(s): %v created, %v already existing, %v invalid. %v newly followed by %v\n",
+		created+existing+invalid,
+		created,
+		existing,
+		invalid,
+		followed,
+		user.Name.String,
+	)
+	return nil
+}
+
+func HandlerExport(s *State, cmd Command, user database.User) error {
+	// Validate Args
+	usage := "usage: export opml [path]"
+	if len(cmd.Args) < 1 {
+		return fmt.Errorf("missing export format. %v", usage)
+	} else if len(cmd.Args) > 2 {
+		return fmt.Errorf("too many arguments. %v", usage)
+	}
+	if cmd.Args[0] != "opml" {
+		return fmt.Errorf("unsupported export format '%v'. %v", cmd.Args[0], usage)
+	}
+
+	context := context.Background()
+
+	// Fetch all feed follows for the current user
+	feedFollows, err := s.DB.GetFeedFollowsForUser(context, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		return fmt.Errorf("unable to get feeds followed by '%v': %v", user.Name.String, err)
+	}
+
+	var subscriptions []opml.Subscription
+	for _, feedFollow := range feedFollows {
+		subscriptions = append(subscriptions, opml.Subscription{
+			Title:   feedFollow.FeedName.String,
+			XMLURL:  feedFollow.FeedUrl.String,
+			HTMLURL: feedFollow.FeedSiteUrl.String,
+			Folder:  feedFollow.Folder.String,
+		})
+	}
+	doc := opml.New(fmt.Sprintf("gator subscriptions of %v", user.Name.String), subscriptions)
+
+	// Write to stdout unless a path is given
+	if len(cmd.Args) == 1 {
+		return doc.Write(os.Stdout)
+	}
+
+	path := cmd.Args[1]
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create '%v': %v", path, err)
+	}
+	defer file.Close()
+
+	err = doc.Write(file)
+	if err != nil {
+		return fmt.Errorf("unable to export to '%v': %v", path, err)
+	}
+	fmt.Printf("exported %v feed(s) to %v\n", len(subscriptions), path)
+	return nil
+}
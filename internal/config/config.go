@@ -20,8 +20,12 @@ func getConfigPath() (string, error) {
 
 // JSON representation of the config file
 type Config struct {
-	DBURL    string `json:"db_url"`
-	UserName string `json:"current_user_name"`
+	DBURL       string `json:"db_url"`
+	UserName    string `json:"current_user_name"`
+	DownloadDir string `json:"download_dir,omitempty"`
+	MaxFeedSize int64  `json:"max_feed_size,omitempty"` // bytes, rss.DefaultMaxBodySize when unset
+	// Consecutive failed fetches before a feed is disabled, negative to never disable
+	DisableAfterFailures int `json:"disable_after_failures,omitempty"`
 }
 
 // Read the config file from the home directory and return the config and any errors
@@ -88,3 +92,25 @@ func (cfg *Config) SetUserName(userName string) error {
 
 	return nil
 }
+
+// Get the directory podcast episodes are downloaded to, defaults to ~/gator/downloads
+func (cfg *Config) GetDownloadDir() (string, error) {
+	if cfg.DownloadDir != "" {
+		return cfg.DownloadDir, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting user home dir: %v", err)
+	}
+	return home + "/gator/downloads", nil
+}
+
+// Get the number of consecutive failures that disables a feed, defaults to 10. A negative
+// value means feeds are never disabled for failing.
+func (cfg *Config) GetDisableAfterFailures() int {
+	if cfg.DisableAfterFailures == 0 {
+		return 10
+	}
+	return cfg.DisableAfterFailures
+}
@@ -17,9 +17,9 @@ const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH inserted AS(
     INSERT INTO feed_follows(id, created_at, updated_at, user_id, feed_id)
     VALUES($1, $2, $3, $4, $5)
-    RETURNING id, created_at, updated_at, user_id, feed_id
+    RETURNING id, created_at, updated_at, user_id, feed_id, folder
 )
-SELECT inserted.id, inserted.created_at, inserted.updated_at, inserted.user_id, inserted.feed_id, users.name AS user_name, feeds.name AS feed_name
+SELECT inserted.id, inserted.created_at, inserted.updated_at, inserted.user_id, inserted.feed_id, inserted.folder, users.name AS user_name, feeds.name AS feed_name
 FROM inserted
 INNER JOIN users
     ON inserted.user_id = users.id
@@ -41,6 +41,7 @@ type CreateFeedFollowRow struct {
 	UpdatedAt time.Time
 	UserID    uuid.NullUUID
 	FeedID    uuid.NullUUID
+	Folder    sql.NullString
 	UserName  sql.NullString
 	FeedName  sql.NullString
 }
@@ -60,6 +61,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.Folder,
 		&i.UserName,
 		&i.FeedName,
 	)
@@ -85,7 +87,7 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 }
 
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
-SELECT feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, users.name AS user_name, feeds.name AS feed_name
+SELECT feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.folder, users.name AS user_name, feeds.name AS feed_name, feeds.url AS feed_url, feeds.site_url AS feed_site_url
 FROM feed_follows
 INNER JOIN users
     ON feed_follows.user_id = users.id
@@ -95,13 +97,16 @@ WHERE feed_follows.user_id =  $1
 `
 
 type GetFeedFollowsForUserRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.NullUUID
-	FeedID    uuid.NullUUID
-	UserName  sql.NullString
-	FeedName  sql.NullString
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.NullUUID
+	FeedID      uuid.NullUUID
+	Folder      sql.NullString
+	UserName    sql.NullString
+	FeedName    sql.NullString
+	FeedUrl     sql.NullString
+	FeedSiteUrl sql.NullString
 }
 
 func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.NullUUID) ([]GetFeedFollowsForUserRow, error) {
@@ -119,8 +124,11 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.NullUUI
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.Folder,
 			&i.UserName,
 			&i.FeedName,
+			&i.FeedUrl,
+			&i.FeedSiteUrl,
 		); err != nil {
 			return nil, err
 		}
@@ -134,3 +142,48 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.NullUUI
 	}
 	return items, nil
 }
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = $1::uuid, updated_at = NOW()
+WHERE feed_id = $2::uuid
+  AND NOT EXISTS (
+    SELECT 1
+    FROM feed_follows AS existing
+    WHERE existing.feed_id = $1::uuid
+      AND existing.user_id = feed_follows.user_id
+  )
+`
+
+type MoveFeedFollowsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const setFeedFollowFolder = `-- name: SetFeedFollowFolder :execrows
+UPDATE feed_follows
+SET folder = $3, updated_at = NOW()
+FROM feeds
+WHERE feed_follows.feed_id = feeds.id
+  AND feed_follows.user_id = $1
+  AND feeds.url = $2
+`
+
+type SetFeedFollowFolderParams struct {
+	UserID uuid.NullUUID
+	Url    sql.NullString
+	Folder sql.NullString
+}
+
+func (q *Queries) SetFeedFollowFolder(ctx context.Context, arg SetFeedFollowFolderParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedFollowFolder, arg.UserID, arg.Url, arg.Folder)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -11,19 +11,82 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const claimNextFeed = `-- name: ClaimNextFeed :one
+UPDATE feeds
+SET last_fetched_at = NOW(), next_fetch_at = $1::timestamp, updated_at = NOW()
+WHERE id = (
+    SELECT id
+    FROM feeds
+    WHERE disabled_at IS NULL
+      AND (next_fetch_at IS NULL OR next_fetch_at <= $2::timestamp)
+    ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, last_error_at, failure_count, site_url, disabled_at, disabled_reason, next_fetch_at, fetch_interval_seconds, skip_hours, skip_days
+`
+
+type ClaimNextFeedParams struct {
+	LeaseUntil time.Time
+	DueAt      time.Time
+}
+
+func (q *Queries) ClaimNextFeed(ctx context.Context, arg ClaimNextFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimNextFeed, arg.LeaseUntil, arg.DueAt)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.FailureCount,
+		&i.SiteUrl,
+		&i.DisabledAt,
+		&i.DisabledReason,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+	)
+	return i, err
+}
+
+const countOverdueFeeds = `-- name: CountOverdueFeeds :one
+SELECT COUNT(*)
+FROM feeds
+WHERE disabled_at IS NULL
+  AND (last_fetched_at IS NULL OR last_fetched_at < NOW() - make_interval(secs => $1::float8))
+`
+
+func (q *Queries) CountOverdueFeeds(ctx context.Context, maxIntervalSeconds float64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOverdueFeeds, maxIntervalSeconds)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds(id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds(id, created_at, updated_at, name, url, user_id, site_url)
 VALUES(
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, last_error_at, failure_count, site_url, disabled_at, disabled_reason, next_fetch_at, fetch_interval_seconds, skip_hours, skip_days
 `
 
 type CreateFeedParams struct {
@@ -33,6 +96,7 @@ type CreateFeedParams struct {
 	Name      sql.NullString
 	Url       sql.NullString
 	UserID    uuid.NullUUID
+	SiteUrl   sql.NullString
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -43,6 +107,7 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.SiteUrl,
 	)
 	var i Feed
 	err := row.Scan(
@@ -53,12 +118,94 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.FailureCount,
+		&i.SiteUrl,
+		&i.DisabledAt,
+		&i.DisabledReason,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const disableFeed = `-- name: DisableFeed :exec
+UPDATE feeds
+SET disabled_at = NOW(), disabled_reason = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type DisableFeedParams struct {
+	ID             uuid.UUID
+	DisabledReason sql.NullString
+}
+
+func (q *Queries) DisableFeed(ctx context.Context, arg DisableFeedParams) error {
+	_, err := q.db.ExecContext(ctx, disableFeed, arg.ID, arg.DisabledReason)
+	return err
+}
+
+const getDisabledFeeds = `-- name: GetDisabledFeeds :many
+SELECT name, url, disabled_at, disabled_reason, last_error
+FROM feeds
+WHERE disabled_at IS NOT NULL
+ORDER BY disabled_at DESC
+`
+
+type GetDisabledFeedsRow struct {
+	Name           sql.NullString
+	Url            sql.NullString
+	DisabledAt     sql.NullTime
+	DisabledReason sql.NullString
+	LastError      sql.NullString
+}
+
+func (q *Queries) GetDisabledFeeds(ctx context.Context) ([]GetDisabledFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDisabledFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDisabledFeedsRow
+	for rows.Next() {
+		var i GetDisabledFeedsRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.LastError,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeed = `-- name: GetFeed :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, last_error_at, failure_count, site_url, disabled_at, disabled_reason, next_fetch_at, fetch_interval_seconds, skip_hours, skip_days FROM feeds
 WHERE url = $1
 `
 
@@ -73,21 +220,38 @@ func (q *Queries) GetFeed(ctx context.Context, url sql.NullString) (Feed, error)
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.FailureCount,
+		&i.SiteUrl,
+		&i.DisabledAt,
+		&i.DisabledReason,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT feeds.name, feeds.url, users.name as user_name
+SELECT feeds.name, feeds.url, feeds.last_error, feeds.last_error_at, feeds.failure_count, feeds.disabled_at, feeds.disabled_reason, users.name as user_name
 FROM feeds
 LEFT JOIN users
 ON feeds.user_id = users.id
 `
 
 type GetFeedsRow struct {
-	Name     sql.NullString
-	Url      sql.NullString
-	UserName sql.NullString
+	Name           sql.NullString
+	Url            sql.NullString
+	LastError      sql.NullString
+	LastErrorAt    sql.NullTime
+	FailureCount   int32
+	DisabledAt     sql.NullTime
+	DisabledReason sql.NullString
+	UserName       sql.NullString
 }
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
@@ -99,7 +263,16 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	var items []GetFeedsRow
 	for rows.Next() {
 		var i GetFeedsRow
-		if err := rows.Scan(&i.Name, &i.Url, &i.UserName); err != nil {
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.FailureCount,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.UserName,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -113,35 +286,134 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
-FROM feeds
-ORDER BY last_fetched_at NULLS FIRST, last_fetched_at ASC
-LIMIT 1
+const markFeedFailed = `-- name: MarkFeedFailed :one
+UPDATE feeds
+SET last_fetched_at = NOW(), last_error = $2, last_error_at = NOW(), failure_count = failure_count + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING failure_count
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
-	var i Feed
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Name,
-		&i.Url,
-		&i.UserID,
-		&i.LastFetchedAt,
+type MarkFeedFailedParams struct {
+	ID        uuid.UUID
+	LastError sql.NullString
+}
+
+func (q *Queries) MarkFeedFailed(ctx context.Context, arg MarkFeedFailedParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, markFeedFailed, arg.ID, arg.LastError)
+	var failure_count int32
+	err := row.Scan(&failure_count)
+	return failure_count, err
+}
+
+const markFeedSucceeded = `-- name: MarkFeedSucceeded :exec
+UPDATE feeds
+SET last_fetched_at = NOW(), failure_count = 0, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkFeedSucceeded(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markFeedSucceeded, id)
+	return err
+}
+
+const reenableFeed = `-- name: ReenableFeed :execrows
+UPDATE feeds
+SET url = COALESCE($1::text, url),
+    disabled_at = NULL,
+    disabled_reason = NULL,
+    failure_count = 0,
+    last_fetched_at = NULL,
+    next_fetch_at = NULL,
+    etag = NULL,
+    last_modified = NULL,
+    updated_at = NOW()
+WHERE url = $2
+  AND disabled_at IS NOT NULL
+`
+
+type ReenableFeedParams struct {
+	NewUrl sql.NullString
+	Url    sql.NullString
+}
+
+func (q *Queries) ReenableFeed(ctx context.Context, arg ReenableFeedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, reenableFeed, arg.NewUrl, arg.Url)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setFeedSchedule = `-- name: SetFeedSchedule :exec
+UPDATE feeds
+SET next_fetch_at = $2, fetch_interval_seconds = $3, skip_hours = $4, skip_days = $5, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedScheduleParams struct {
+	ID                   uuid.UUID
+	NextFetchAt          sql.NullTime
+	FetchIntervalSeconds sql.NullInt32
+	SkipHours            []int32
+	SkipDays             []int32
+}
+
+func (q *Queries) SetFeedSchedule(ctx context.Context, arg SetFeedScheduleParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedSchedule,
+		arg.ID,
+		arg.NextFetchAt,
+		arg.FetchIntervalSeconds,
+		pq.Array(arg.SkipHours),
+		pq.Array(arg.SkipDays),
 	)
-	return i, err
+	return err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :exec
+const updateFeedCacheHeaders = `-- name: UpdateFeedCacheHeaders :exec
 UPDATE feeds
-SET last_fetched_at = NOW(), updated_at = NOW()
+SET etag = $2, last_modified = $3, updated_at = NOW()
 WHERE id = $1
 `
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
+type UpdateFeedCacheHeadersParams struct {
+	ID           uuid.UUID
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) UpdateFeedCacheHeaders(ctx context.Context, arg UpdateFeedCacheHeadersParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCacheHeaders, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const updateFeedSiteURL = `-- name: UpdateFeedSiteURL :exec
+UPDATE feeds
+SET site_url = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedSiteURLParams struct {
+	ID      uuid.UUID
+	SiteUrl sql.NullString
+}
+
+func (q *Queries) UpdateFeedSiteURL(ctx context.Context, arg UpdateFeedSiteURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedSiteURL, arg.ID, arg.SiteUrl)
+	return err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET url = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedURLParams struct {
+	ID  uuid.UUID
+	Url sql.NullString
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.ID, arg.Url)
 	return err
 }
@@ -11,14 +11,53 @@ import (
 	"github.com/google/uuid"
 )
 
+type Enclosure struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	Type            sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+	Episode         sql.NullInt32
+	Season          sql.NullInt32
+	FilePath        sql.NullString
+	DownloadedAt    sql.NullTime
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          sql.NullString
-	Url           sql.NullString
-	UserID        uuid.NullUUID
-	LastFetchedAt sql.NullTime
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Name                 sql.NullString
+	Url                  sql.NullString
+	UserID               uuid.NullUUID
+	LastFetchedAt        sql.NullTime
+	Etag                 sql.NullString
+	LastModified         sql.NullString
+	LastError            sql.NullString
+	LastErrorAt          sql.NullTime
+	FailureCount         int32
+	SiteUrl              sql.NullString
+	DisabledAt           sql.NullTime
+	DisabledReason       sql.NullString
+	NextFetchAt          sql.NullTime
+	FetchIntervalSeconds sql.NullInt32
+	SkipHours            []int32
+	SkipDays             []int32
+}
+
+type FeedFetch struct {
+	ID         uuid.UUID
+	FeedID     uuid.UUID
+	StartedAt  time.Time
+	DurationMs int32
+	StatusCode sql.NullInt32
+	Bytes      sql.NullInt64
+	Items      int32
+	Inserted   int32
+	Error      sql.NullString
 }
 
 type FeedFollow struct {
@@ -27,17 +66,45 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.NullUUID
 	FeedID    uuid.NullUUID
+	Folder    sql.NullString
 }
 
 type Post struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Title           sql.NullString
+	Url             sql.NullString
+	Description     sql.NullString
+	PublishedAt     sql.NullTime
+	FeedID          uuid.NullUUID
+	Guid            string
+	ContentHash     sql.NullString
+	Content         sql.NullString
+	Author          sql.NullString
+	EnclosureUrl    sql.NullString
+	EnclosureType   sql.NullString
+	EnclosureLength sql.NullInt64
+}
+
+type PostCategory struct {
+	PostID uuid.UUID
+	Name   string
+}
+
+type PostRevision struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	PostID      uuid.UUID
 	Title       sql.NullString
-	Url         sql.NullString
 	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.NullUUID
+	ContentHash string
+}
+
+type PostView struct {
+	UserID   uuid.UUID
+	PostID   uuid.UUID
+	ViewedAt time.Time
 }
 
 type User struct {
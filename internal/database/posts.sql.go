@@ -13,74 +13,170 @@ import (
 	"github.com/google/uuid"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+const createPostCategory = `-- name: CreatePostCategory :exec
+INSERT INTO post_categories (post_id, name)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
 `
 
-type CreatePostParams struct {
+type CreatePostCategoryParams struct {
+	PostID uuid.UUID
+	Name   string
+}
+
+func (q *Queries) CreatePostCategory(ctx context.Context, arg CreatePostCategoryParams) error {
+	_, err := q.db.ExecContext(ctx, createPostCategory, arg.PostID, arg.Name)
+	return err
+}
+
+const createPostRevision = `-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (id, created_at, post_id, title, description, content_hash)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type CreatePostRevisionParams struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	PostID      uuid.UUID
 	Title       sql.NullString
-	Url         sql.NullString
 	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.NullUUID
+	ContentHash string
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
+func (q *Queries) CreatePostRevision(ctx context.Context, arg CreatePostRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createPostRevision,
 		arg.ID,
 		arg.CreatedAt,
-		arg.UpdatedAt,
+		arg.PostID,
 		arg.Title,
-		arg.Url,
 		arg.Description,
-		arg.PublishedAt,
-		arg.FeedID,
-	)
-	var i Post
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Title,
-		&i.Url,
-		&i.Description,
-		&i.PublishedAt,
-		&i.FeedID,
+		arg.ContentHash,
 	)
+	return err
+}
+
+const deletePostCategories = `-- name: DeletePostCategories :exec
+DELETE FROM post_categories
+WHERE post_id = $1
+`
+
+func (q *Queries) DeletePostCategories(ctx context.Context, postID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deletePostCategories, postID)
+	return err
+}
+
+const getPostCategories = `-- name: GetPostCategories :many
+SELECT name
+FROM post_categories
+WHERE post_id = $1
+ORDER BY name
+`
+
+func (q *Queries) GetPostCategories(ctx context.Context, postID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getPostCategories, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostContentHash = `-- name: GetPostContentHash :one
+SELECT content_hash, updated_at
+FROM posts
+WHERE feed_id = $1
+  AND guid = $2
+`
+
+type GetPostContentHashParams struct {
+	FeedID uuid.NullUUID
+	Guid   string
+}
+
+type GetPostContentHashRow struct {
+	ContentHash sql.NullString
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) GetPostContentHash(ctx context.Context, arg GetPostContentHashParams) (GetPostContentHashRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostContentHash, arg.FeedID, arg.Guid)
+	var i GetPostContentHashRow
+	err := row.Scan(&i.ContentHash, &i.UpdatedAt)
 	return i, err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.guid, posts.content_hash, posts.content, posts.author, posts.enclosure_url, posts.enclosure_type, posts.enclosure_length, (post_views.viewed_at IS NOT NULL AND posts.updated_at > post_views.viewed_at)::boolean AS updated_since_seen
 FROM posts
 INNER JOIN feed_follows
     ON feed_follows.feed_id = posts.feed_id
 INNER JOIN users
     ON users.id = feed_follows.user_id
+LEFT JOIN post_views
+    ON post_views.post_id = posts.id
+    AND post_views.user_id = users.id
 WHERE users.id = $1
-ORDER BY posts.published_at DESC
-LIMIT $2
+  AND (
+    $2::text IS NULL
+    OR EXISTS (
+        SELECT 1
+        FROM post_categories
+        WHERE post_categories.post_id = posts.id
+          AND post_categories.name = $2::text
+    )
+  )
+ORDER BY COALESCE(posts.published_at, posts.created_at) DESC
+LIMIT $3
 `
 
 type GetPostsForUserParams struct {
-	ID    uuid.UUID
-	Limit int32
+	ID       uuid.UUID
+	Category sql.NullString
+	Limit    int32
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.ID, arg.Limit)
+type GetPostsForUserRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              sql.NullString
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.NullUUID
+	Guid             string
+	ContentHash      sql.NullString
+	Content          sql.NullString
+	Author           sql.NullString
+	EnclosureUrl     sql.NullString
+	EnclosureType    sql.NullString
+	EnclosureLength  sql.NullInt64
+	UpdatedSinceSeen bool
+}
+
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.ID, arg.Category, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsForUserRow
 	for rows.Next() {
-		var i Post
+		var i GetPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -90,6 +186,14 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Guid,
+			&i.ContentHash,
+			&i.Content,
+			&i.Author,
+			&i.EnclosureUrl,
+			&i.EnclosureType,
+			&i.EnclosureLength,
+			&i.UpdatedSinceSeen,
 		); err != nil {
 			return nil, err
 		}
@@ -103,3 +207,183 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const getRecentPublishTimes = `-- name: GetRecentPublishTimes :many
+SELECT published_at
+FROM posts
+WHERE feed_id = $1
+  AND published_at IS NOT NULL
+ORDER BY published_at DESC
+LIMIT $2
+`
+
+type GetRecentPublishTimesParams struct {
+	FeedID uuid.NullUUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentPublishTimes(ctx context.Context, arg GetRecentPublishTimesParams) ([]sql.NullTime, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPublishTimes, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullTime
+	for rows.Next() {
+		var published_at sql.NullTime
+		if err := rows.Scan(&published_at); err != nil {
+			return nil, err
+		}
+		items = append(items, published_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markPostViewed = `-- name: MarkPostViewed :exec
+INSERT INTO post_views (user_id, post_id, viewed_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET viewed_at = EXCLUDED.viewed_at
+`
+
+type MarkPostViewedParams struct {
+	UserID   uuid.UUID
+	PostID   uuid.UUID
+	ViewedAt time.Time
+}
+
+func (q *Queries) MarkPostViewed(ctx context.Context, arg MarkPostViewedParams) error {
+	_, err := q.db.ExecContext(ctx, markPostViewed, arg.UserID, arg.PostID, arg.ViewedAt)
+	return err
+}
+
+const movePosts = `-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = $1::uuid
+WHERE feed_id = $2::uuid
+  AND NOT EXISTS (
+    SELECT 1
+    FROM posts AS existing
+    WHERE existing.feed_id = $1::uuid
+      AND existing.guid = posts.guid
+  )
+`
+
+type MovePostsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MovePosts(ctx context.Context, arg MovePostsParams) error {
+	_, err := q.db.ExecContext(ctx, movePosts, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const rekeyLegacyPost = `-- name: RekeyLegacyPost :exec
+UPDATE posts
+SET guid = $1
+WHERE feed_id = $2
+  AND guid = 'legacy:' || $3::text
+  AND NOT EXISTS (
+    SELECT 1
+    FROM posts AS existing
+    WHERE existing.feed_id = $2
+      AND existing.guid = $1
+  )
+`
+
+type RekeyLegacyPostParams struct {
+	Guid   string
+	FeedID uuid.NullUUID
+	Url    string
+}
+
+func (q *Queries) RekeyLegacyPost(ctx context.Context, arg RekeyLegacyPostParams) error {
+	_, err := q.db.ExecContext(ctx, rekeyLegacyPost, arg.Guid, arg.FeedID, arg.Url)
+	return err
+}
+
+const upsertPost = `-- name: UpsertPost :one
+INSERT INTO posts (
+    id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash,
+    content, author, enclosure_url, enclosure_type, enclosure_length
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = EXCLUDED.published_at,
+    content_hash = EXCLUDED.content_hash,
+    content = EXCLUDED.content,
+    author = EXCLUDED.author,
+    enclosure_url = EXCLUDED.enclosure_url,
+    enclosure_type = EXCLUDED.enclosure_type,
+    enclosure_length = EXCLUDED.enclosure_length,
+    updated_at = EXCLUDED.updated_at
+WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, content, author, enclosure_url, enclosure_type, enclosure_length
+`
+
+type UpsertPostParams struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Title           sql.NullString
+	Url             sql.NullString
+	Description     sql.NullString
+	PublishedAt     sql.NullTime
+	FeedID          uuid.NullUUID
+	Guid            string
+	ContentHash     sql.NullString
+	Content         sql.NullString
+	Author          sql.NullString
+	EnclosureUrl    sql.NullString
+	EnclosureType   sql.NullString
+	EnclosureLength sql.NullInt64
+}
+
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Guid,
+		arg.ContentHash,
+		arg.Content,
+		arg.Author,
+		arg.EnclosureUrl,
+		arg.EnclosureType,
+		arg.EnclosureLength,
+	)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.Content,
+		&i.Author,
+		&i.EnclosureUrl,
+		&i.EnclosureType,
+		&i.EnclosureLength,
+	)
+	return i, err
+}
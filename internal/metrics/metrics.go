@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector is a metric that can write itself in the Prometheus text exposition format
+type Collector interface {
+	collect(w io.Writer) error
+}
+
+// Registry holds the metrics exposed by its handler
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds metrics to the registry, they are exposed in the order registered
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Handler serves every registered metric in the Prometheus text format. A metric that
+// fails to collect is left out and logged, the others are still served.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		collectors := slices.Clone(r.collectors)
+		r.mu.Unlock()
+
+		var b bytes.Buffer
+		for _, c := range collectors {
+			// Collect into a separate buffer so a failed metric leaves nothing behind
+			var metric bytes.Buffer
+			if err := c.collect(&metric); err != nil {
+				fmt.Printf("error collecting metrics: %v\n", err)
+				continue
+			}
+			b.Write(metric.Bytes())
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(b.Bytes())
+	})
+}
+
+// Write the HELP and TYPE lines that start every metric
+func writeHeader(w io.Writer, name, help, kind string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+// Format label names and values as {name="value",...}, empty when there are none
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%v="%v"`, name, escape.Replace(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// CounterVec is a set of counters partitioned by label values
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64
+	order  [][]string
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+	// A counter without labels is exposed at zero before its first increment
+	if len(labels) == 0 {
+		c.Add(0)
+	}
+	return c
+}
+
+// Add increases the counter with the given label values, one value per label
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if len(labelValues) != len(c.labels) {
+		panic(fmt.Sprintf("metric %v expects %v label value(s), got %v", c.name, len(c.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.values[key]; !ok {
+		c.order = append(c.order, slices.Clone(labelValues))
+	}
+	c.values[key] += value
+}
+
+// Inc increases the counter with the given label values by one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) collect(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+
+	// Sort by label values so the output is stable between scrapes
+	order := slices.Clone(c.order)
+	slices.SortFunc(order, slices.Compare)
+	for _, labelValues := range order {
+		value := c.values[strings.Join(labelValues, "\xff")]
+		fmt.Fprintf(w, "%v%v %v\n", c.name, formatLabels(c.labels, labelValues), formatValue(value))
+	}
+	return nil
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	mu      sync.Mutex
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds, a +Inf bucket is
+// always added
+func NewHistogram(name, help string, buckets ...float64) *Histogram {
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (h *Histogram) collect(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%v_bucket{le=\"%v\"} %v\n", h.name, formatValue(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%v_bucket{le=\"+Inf\"} %v\n", h.name, h.count)
+	fmt.Fprintf(w, "%v_sum %v\n", h.name, formatValue(h.sum))
+	fmt.Fprintf(w, "%v_count %v\n", h.name, h.count)
+	return nil
+}
+
+// GaugeFunc is a gauge whose value is computed every time the metrics are collected
+type GaugeFunc struct {
+	name  string
+	help  string
+	value func() (float64, error)
+}
+
+func NewGaugeFunc(name, help string, value func() (float64, error)) *GaugeFunc {
+	return &GaugeFunc{
+		name:  name,
+		help:  help,
+		value: value,
+	}
+}
+
+func (g *GaugeFunc) collect(w io.Writer) error {
+	value, err := g.value()
+	if err != nil {
+		return fmt.Errorf("%v: %v", g.name, err)
+	}
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%v %v\n", g.name, formatValue(value))
+	return nil
+}
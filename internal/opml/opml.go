@@ -0,0 +1,139 @@
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// OPML 2.0 document as described by http://opml.org/spec2.opml
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+// Outlines with an xmlUrl are feeds, outlines with children are folders
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Subscription is a feed outline flattened out of its folders
+type Subscription struct {
+	Title   string
+	XMLURL  string
+	HTMLURL string
+	Folder  string // names of the enclosing folder outlines joined by "/"
+}
+
+// Parse reads an OPML document
+func Parse(r io.Reader) (*OPML, error) {
+	var doc OPML
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding opml: %v", err)
+	}
+	return &doc, nil
+}
+
+// Subscriptions returns every feed outline in the document along with the leaf outlines
+// that have no xmlUrl, which callers can report as invalid entries
+func (o *OPML) Subscriptions() []Subscription {
+	var subscriptions []Subscription
+	var walk func(outlines []Outline, folders []string)
+	walk = func(outlines []Outline, folders []string) {
+		for _, outline := range outlines {
+			title := strings.TrimSpace(outline.Title)
+			if title == "" {
+				title = strings.TrimSpace(outline.Text)
+			}
+
+			if outline.XMLURL != "" || len(outline.Outlines) == 0 {
+				subscriptions = append(subscriptions, Subscription{
+					Title:   title,
+					XMLURL:  strings.TrimSpace(outline.XMLURL),
+					HTMLURL: strings.TrimSpace(outline.HTMLURL),
+					Folder:  strings.Join(folders, "/"),
+				})
+			}
+			if len(outline.Outlines) > 0 {
+				walk(outline.Outlines, append(folders[:len(folders):len(folders)], title))
+			}
+		}
+	}
+	walk(o.Body.Outlines, nil)
+	return subscriptions
+}
+
+// New builds an OPML document from subscriptions, nesting them in folder outlines
+func New(title string, subscriptions []Subscription) *OPML {
+	doc := &OPML{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: time.Now().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, sub := range subscriptions {
+		// Find or create the folder outlines along the subscription's folder path
+		outlines := &doc.Body.Outlines
+		if sub.Folder != "" {
+			for _, name := range strings.Split(sub.Folder, "/") {
+				outlines = &folderOutline(outlines, name).Outlines
+			}
+		}
+
+		*outlines = append(*outlines, Outline{
+			Text:    sub.Title,
+			Title:   sub.Title,
+			Type:    "rss",
+			XMLURL:  sub.XMLURL,
+			HTMLURL: sub.HTMLURL,
+		})
+	}
+	return doc
+}
+
+// Get the folder outline with the given name, appending it when it does not exist yet
+func folderOutline(outlines *[]Outline, name string) *Outline {
+	for idx := range *outlines {
+		outline := &(*outlines)[idx]
+		if outline.XMLURL == "" && outline.Text == name {
+			return outline
+		}
+	}
+	*outlines = append(*outlines, Outline{Text: name})
+	return &(*outlines)[len(*outlines)-1]
+}
+
+// Write the document as indented XML
+func (o *OPML) Write(w io.Writer) error {
+	data, err := xml.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding opml: %v", err)
+	}
+
+	_, err = io.WriteString(w, xml.Header+string(data)+"\n")
+	if err != nil {
+		return fmt.Errorf("error writing opml: %v", err)
+	}
+	return nil
+}
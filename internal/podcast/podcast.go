@@ -0,0 +1,165 @@
+package podcast
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Longest file or directory name produced by SanitizeName
+const maxNameLength = 100
+
+// SanitizeName makes a feed or episode title safe to use as a file or directory name
+func SanitizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	sanitized := strings.Trim(b.String(), "._")
+	if runes := []rune(sanitized); len(runes) > maxNameLength {
+		sanitized = string(runes[:maxNameLength])
+	}
+	return sanitized
+}
+
+// FileName builds the file name of an episode from its title and the extension of the
+// enclosure, falling back to the name of the file in the enclosure URL. A short hash of the
+// URL keeps episodes with the same title from sharing a file.
+func FileName(title, fileURL, mimeType string) string {
+	var base, ext string
+	if u, err := url.Parse(fileURL); err == nil {
+		base = path.Base(u.Path)
+		ext = path.Ext(u.Path)
+	}
+	if ext == "" && mimeType != "" {
+		if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+
+	name := SanitizeName(title)
+	if name == "" {
+		name = SanitizeName(strings.TrimSuffix(base, path.Ext(base)))
+	}
+	if name == "" {
+		name = "episode"
+	}
+	sum := sha256.Sum256([]byte(fileURL))
+	return name + "-" + hex.EncodeToString(sum[:4]) + ext
+}
+
+// Download the file at fileURL to filePath and return its size. Data is written to a
+// ".part" file first so an interrupted download resumes where it left off.
+func Download(ctx context.Context, fileURL, filePath string) (int64, error) {
+	err := os.MkdirAll(filepath.Dir(filePath), 0o755)
+	if err != nil {
+		return 0, fmt.Errorf("error creating directory for '%v': %v", filePath, err)
+	}
+
+	partPath := filePath + ".part"
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error making request to '%v': %v", fileURL, err)
+	}
+	req.Header.Set("User-Agent", "gator")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	// No client timeout since episodes can take a long time, ctx bounds the download
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error getting response from '%v': %v", fileURL, err)
+	}
+	defer res.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		// Appending a range that doesn't start at the end of the partial file would corrupt
+		// it, so discard what was downloaded and start over
+		if start, ok := contentRangeStart(res.Header.Get("Content-Range")); !ok || start != offset {
+			res.Body.Close()
+			err := os.Remove(partPath)
+			if err != nil {
+				return 0, fmt.Errorf("error removing '%v': %v", partPath, err)
+			}
+			return Download(ctx, fileURL, filePath)
+		}
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// The server ignored the range so start over
+		offset = 0
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Nothing is left to fetch when the partial file already holds the whole episode
+		if offset == 0 {
+			return 0, fmt.Errorf("unexpected status downloading '%v': %v", fileURL, res.Status)
+		}
+		return offset, finish(partPath, filePath)
+	default:
+		return 0, fmt.Errorf("unexpected status downloading '%v': %v", fileURL, res.Status)
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("error opening '%v': %v", partPath, err)
+	}
+	n, err := io.Copy(file, res.Body)
+	closeErr := file.Close()
+	if err != nil {
+		return 0, fmt.Errorf("error downloading '%v': %v", fileURL, err)
+	}
+	if closeErr != nil {
+		return 0, fmt.Errorf("error writing '%v': %v", partPath, closeErr)
+	}
+
+	return offset + n, finish(partPath, filePath)
+}
+
+// Get the first byte of a Content-Range header such as "bytes 100-199/200"
+func contentRangeStart(value string) (int64, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
+// Move a completed partial download into place
+func finish(partPath, filePath string) error {
+	err := os.Rename(partPath, filePath)
+	if err != nil {
+		return fmt.Errorf("error moving '%v' to '%v': %v", partPath, filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package rss
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// Atom 1.0 document as described by RFC 4287
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"feed"`
+	Title    atomText    `xml:"title"`
+	Subtitle atomText    `xml:"subtitle"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Summary    atomText       `xml:"summary"`
+	Content    atomText       `xml:"content"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+// Atom text constructs are either plain text, escaped html or inline xhtml
+type atomText struct {
+	Type     string    `xml:"type,attr"`
+	Text     string    `xml:",chardata"`
+	InnerXML string    `xml:",innerxml"`
+	Div      *xhtmlDiv `xml:"http://www.w3.org/1999/xhtml div"`
+}
+
+// The div that wraps inline xhtml, which is not part of the content
+type xhtmlDiv struct {
+	InnerXML string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		if t.Div != nil {
+			return strings.TrimSpace(t.Div.InnerXML)
+		}
+		return strings.TrimSpace(t.InnerXML)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// Get the href of the alternate link, falling back to the first link
+func alternateLink(links []atomLink) string {
+	for _, link := range links {
+		// A missing rel is treated as "alternate" by the spec
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// Unmarshal an Atom document and normalize it into an RSSFeed
+func parseAtom(body []byte, charset string) (*RSSFeed, error) {
+	var atom atomFeed
+	err := newDecoder(body, charset).Decode(&atom)
+	if err != nil {
+		return nil, err
+	}
+
+	var feed RSSFeed
+	feed.Channel.Title = atom.Title.String()
+	feed.Channel.Link = alternateLink(atom.Links)
+	feed.Channel.Description = atom.Subtitle.String()
+
+	for _, entry := range atom.Entries {
+		item := RSSItem{
+			GUID:        strings.TrimSpace(entry.ID),
+			Title:       entry.Title.String(),
+			Link:        alternateLink(entry.Links),
+			Description: entry.Summary.String(),
+			Content:     entry.Content.String(),
+			PubDate:     strings.TrimSpace(entry.Published),
+		}
+		if item.Description == "" {
+			item.Description = item.Content
+		}
+		if item.PubDate == "" {
+			item.PubDate = strings.TrimSpace(entry.Updated)
+		}
+
+		var names []string
+		for _, author := range entry.Authors {
+			if name := strings.TrimSpace(author.Name); name != "" {
+				names = append(names, name)
+			}
+		}
+		item.Author = strings.Join(names, ", ")
+
+		for _, category := range entry.Categories {
+			if category.Term != "" {
+				item.Categories = append(item.Categories, category.Term)
+			} else if category.Label != "" {
+				item.Categories = append(item.Categories, category.Label)
+			}
+		}
+
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" {
+				item.Enclosures = append(item.Enclosures, RSSEnclosure{
+					URL:    link.Href,
+					Length: link.Length,
+					Type:   link.Type,
+				})
+			}
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+
+	return &feed, nil
+}
@@ -0,0 +1,95 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// Characters of windows-1252 in the 0x80-0x9F range, where it differs from ISO-8859-1.
+// Bytes the code page leaves undefined map to the control character of the same value.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', '\u008D', 'Ž', '\u008F',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', '\u009D', 'ž', 'Ÿ',
+}
+
+// Get the charset parameter of a Content-Type header, empty when there is none
+func contentTypeCharset(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
+
+// Report whether the named charset is UTF-8 or its ASCII subset
+func isUTF8(charset string) bool {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return true
+	default:
+		return false
+	}
+}
+
+// Convert the input from the named charset to UTF-8. ISO-8859-1 is decoded as windows-1252
+// like browsers do, since feeds labelled latin1 are often written with smart quotes and
+// dashes from the windows code page.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	if isUTF8(charset) {
+		return input, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1", "windows-1252", "cp1252", "x-cp1252":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		b.Grow(len(data))
+		for _, c := range data {
+			if c >= 0x80 && c <= 0x9F {
+				b.WriteRune(windows1252[c-0x80])
+			} else {
+				b.WriteRune(rune(c))
+			}
+		}
+		return strings.NewReader(b.String()), nil
+	default:
+		return nil, fmt.Errorf("unsupported charset '%v'", charset)
+	}
+}
+
+// Create a decoder that reads the body as UTF-8. The charset from the Content-Type header
+// takes precedence over the encoding in the XML declaration, which is used otherwise.
+func newDecoder(body []byte, charset string) *xml.Decoder {
+	if charset == "" {
+		decoder := xml.NewDecoder(bytes.NewReader(body))
+		decoder.CharsetReader = charsetReader
+		return decoder
+	}
+
+	// Servers often label every response as UTF-8, so a body that isn't valid UTF-8 is
+	// decoded with the encoding from its XML declaration instead
+	if isUTF8(charset) && !utf8.Valid(body) {
+		return newDecoder(body, "")
+	}
+
+	input, err := charsetReader(charset, bytes.NewReader(body))
+	if err != nil {
+		// Fall back to the XML declaration when the header names a charset we don't know
+		return newDecoder(body, "")
+	}
+	decoder := xml.NewDecoder(input)
+	// The body is already UTF-8, so the declared encoding is ignored
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
@@ -0,0 +1,103 @@
+package rss
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Feed advertised by an HTML page with <link rel="alternate">
+type FeedLink struct {
+	URL   string
+	Title string
+	Type  string
+}
+
+// HTMLPageError is returned by FetchFeed when the URL serves an HTML page rather than a
+// feed, along with the feeds the page advertises
+type HTMLPageError struct {
+	URL   string
+	Feeds []FeedLink
+}
+
+func (e *HTMLPageError) Error() string {
+	return fmt.Sprintf("'%v' is an html page, not a feed (%v feed link(s) found)", e.URL, len(e.Feeds))
+}
+
+// Media types of feeds that may be advertised by a page
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/rdf+xml":   true,
+}
+
+var (
+	linkTagPattern = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	baseTagPattern = regexp.MustCompile(`(?is)<base\b[^>]*>`)
+	attrPattern    = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// Report whether a response that isn't a feed is an HTML page, from its content type or body
+func isHTML(contentType string, body []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml") {
+		return true
+	}
+
+	start := bytes.ToLower(bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))))
+	return bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.HasPrefix(start, []byte("<html"))
+}
+
+// Get the attributes of an HTML tag with lowercase names and unescaped values
+func tagAttrs(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, match := range attrPattern.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(match[1])] = html.UnescapeString(match[2] + match[3] + match[4])
+	}
+	return attrs
+}
+
+// Find the feeds advertised by an HTML page, resolving their hrefs against the page URL
+// or its <base href>
+func discoverFeeds(pageURL string, body []byte) []FeedLink {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	if tag := baseTagPattern.Find(body); tag != nil {
+		if href, ok := tagAttrs(string(tag))["href"]; ok {
+			if resolved, err := base.Parse(strings.TrimSpace(href)); err == nil {
+				base = resolved
+			}
+		}
+	}
+
+	var feeds []FeedLink
+	seen := make(map[string]bool)
+	for _, tag := range linkTagPattern.FindAll(body, -1) {
+		attrs := tagAttrs(string(tag))
+		rels := strings.Fields(strings.ToLower(attrs["rel"]))
+		feedType := strings.ToLower(strings.TrimSpace(attrs["type"]))
+		if !slices.Contains(rels, "alternate") || !feedLinkTypes[feedType] {
+			continue
+		}
+
+		href, err := base.Parse(strings.TrimSpace(attrs["href"]))
+		if err != nil || attrs["href"] == "" || seen[href.String()] {
+			continue
+		}
+		seen[href.String()] = true
+		feeds = append(feeds, FeedLink{
+			URL:   href.String(),
+			Title: strings.TrimSpace(attrs["title"]),
+			Type:  feedType,
+		})
+	}
+	return feeds
+}
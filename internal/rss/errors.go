@@ -0,0 +1,97 @@
+package rss
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound        = errors.New("feed not found")
+	ErrGone            = errors.New("feed is gone")
+	ErrTooLarge        = errors.New("feed is too large")
+	ErrUnsupportedType = errors.New("unsupported content type")
+	ErrUnparsable      = errors.New("unable to parse feed")
+)
+
+// HTTPError is returned for responses with a status other than 2xx or 304. It unwraps to
+// ErrNotFound or ErrGone for those statuses. RetryAfter is how long the server asked to
+// wait before the next request, zero when it didn't send a Retry-After header.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("unexpected status from '%v': %v, retry after %v", e.URL, e.Status, e.RetryAfter)
+	}
+	return fmt.Sprintf("unexpected status from '%v': %v", e.URL, e.Status)
+}
+
+func (e *HTTPError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusGone:
+		return ErrGone
+	default:
+		return nil
+	}
+}
+
+// Temporary reports whether the same request may succeed later
+func (e *HTTPError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500
+}
+
+// Parse a Retry-After header given either as seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// RetryAfter returns the wait the server asked for when a fetch failed, or zero
+func RetryAfter(err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.RetryAfter
+	}
+	return 0
+}
+
+// IsRateLimited reports whether the server asked us to slow down, with a 429 or a 503
+func IsRateLimited(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests ||
+			httpErr.StatusCode == http.StatusServiceUnavailable
+	}
+	return false
+}
+
+// IsTemporary reports whether a fetch failed for a reason worth retrying, such as a
+// server error or a network failure. Missing feeds, oversized bodies and documents that
+// can't be parsed fail the same way every time.
+func IsTemporary(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Temporary()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,148 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// JSON Feed document as described by https://www.jsonfeed.org/version/1.1/
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            jsonFeedID           `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Authors       []jsonFeedAuthor     `json:"authors"`
+	Author        *jsonFeedAuthor      `json:"author"` // deprecated in 1.1
+	Tags          []string             `json:"tags"`
+	Attachments   []jsonFeedAttachment `json:"attachments"`
+}
+
+// Item id, which the spec asks readers to accept as a number and convert to a string
+type jsonFeedID string
+
+func (id *jsonFeedID) UnmarshalJSON(data []byte) error {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	if err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case string:
+		*id = jsonFeedID(v)
+	case json.Number:
+		*id = jsonFeedID(v.String())
+	case nil:
+		*id = ""
+	default:
+		return fmt.Errorf("item id must be a string or a number, got %v", string(data))
+	}
+	return nil
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Report whether the response looks like a JSON Feed from its content type or body
+func isJSONFeed(contentType string, body []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "application/feed+json" || mediaType == "application/json") {
+		return true
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("{"))
+}
+
+// Unmarshal a JSON Feed document and normalize it into an RSSFeed
+func parseJSONFeed(body []byte) (*RSSFeed, error) {
+	var jf jsonFeed
+	err := json.Unmarshal(body, &jf)
+	if err != nil {
+		return nil, err
+	}
+	// Any JSON document would otherwise pass as an empty feed
+	if !strings.HasPrefix(jf.Version, "https://jsonfeed.org/version/") {
+		return nil, fmt.Errorf("not a JSON Feed, unknown version '%v'", jf.Version)
+	}
+
+	var feed RSSFeed
+	feed.Channel.Title = jf.Title
+	feed.Channel.Link = jf.HomePageURL
+	feed.Channel.Description = jf.Description
+
+	for _, i := range jf.Items {
+		item := RSSItem{
+			GUID:        string(i.ID),
+			Title:       i.Title,
+			Link:        i.URL,
+			Description: i.Summary,
+			Content:     i.ContentHTML,
+			PubDate:     i.DatePublished,
+			Categories:  i.Tags,
+		}
+		if item.Link == "" {
+			item.Link = i.ExternalURL
+		}
+		if item.Description == "" {
+			item.Description = i.ContentHTML
+		}
+		if item.Description == "" {
+			item.Description = i.ContentText
+		}
+		if item.PubDate == "" {
+			item.PubDate = i.DateModified
+		}
+
+		authors := i.Authors
+		if len(authors) == 0 && i.Author != nil {
+			authors = []jsonFeedAuthor{*i.Author}
+		}
+		var names []string
+		for _, author := range authors {
+			if author.Name != "" {
+				names = append(names, author.Name)
+			}
+		}
+		item.Author = strings.Join(names, ", ")
+
+		for _, attachment := range i.Attachments {
+			enclosure := RSSEnclosure{
+				URL:  attachment.URL,
+				Type: attachment.MimeType,
+			}
+			if attachment.SizeInBytes > 0 {
+				enclosure.Length = strconv.FormatInt(attachment.SizeInBytes, 10)
+			}
+			item.Enclosures = append(item.Enclosures, enclosure)
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+
+	return &feed, nil
+}
@@ -0,0 +1,66 @@
+package rss
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// RSS 1.0 document, items are siblings of the channel rather than children
+type rdfFeed struct {
+	XMLName xml.Name `xml:"RDF"`
+	Channel struct {
+		Title           string `xml:"title"`
+		Link            string `xml:"link"`
+		Description     string `xml:"description"`
+		UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	} `xml:"channel"`
+	Items []rdfItem `xml:"item"`
+}
+
+type rdfItem struct {
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+}
+
+// Unmarshal an RSS 1.0 (RDF) document and normalize it into an RSSFeed
+func parseRDF(body []byte, charset string) (*RSSFeed, error) {
+	var rdf rdfFeed
+	err := newDecoder(body, charset).Decode(&rdf)
+	if err != nil {
+		return nil, err
+	}
+
+	var feed RSSFeed
+	feed.Channel.Title = strings.TrimSpace(rdf.Channel.Title)
+	feed.Channel.Link = strings.TrimSpace(rdf.Channel.Link)
+	feed.Channel.Description = strings.TrimSpace(rdf.Channel.Description)
+	feed.Channel.UpdatePeriod = strings.TrimSpace(rdf.Channel.UpdatePeriod)
+	feed.Channel.UpdateFrequency = strings.TrimSpace(rdf.Channel.UpdateFrequency)
+
+	for _, i := range rdf.Items {
+		item := RSSItem{
+			GUID:        strings.TrimSpace(i.About),
+			Title:       strings.TrimSpace(i.Title),
+			Link:        strings.TrimSpace(i.Link),
+			Description: strings.TrimSpace(i.Description),
+			Content:     strings.TrimSpace(i.Content),
+			PubDate:     strings.TrimSpace(i.Date),
+			Author:      strings.TrimSpace(i.Creator),
+			Categories:  i.Subjects,
+		}
+		// rdf:about is the item's URI and usually matches its link
+		if item.Link == "" {
+			item.Link = strings.TrimSpace(i.About)
+		}
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+
+	return &feed, nil
+}
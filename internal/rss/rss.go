@@ -2,20 +2,28 @@ package rss
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var rssTimeFormats = []string{
-	time.RFC1123Z, // "Mon, 02 Jan 2006 15:04:05 -0700"
-	time.RFC1123,  // "Mon, 02 Jan 2006 15:04:05 MST"
-	time.RFC822Z,  // "02 Jan 06 15:04 -0700"
-	time.RFC822,   // "02 Jan 06 15:04 MST"
-	time.RFC3339,  // "2006-01-02T15:04:05Z07:00"
+	time.RFC1123Z,            // "Mon, 02 Jan 2006 15:04:05 -0700"
+	time.RFC1123,             // "Mon, 02 Jan 2006 15:04:05 MST"
+	time.RFC822Z,             // "02 Jan 06 15:04 -0700"
+	time.RFC822,              // "02 Jan 06 15:04 MST"
+	time.RFC3339,             // "2006-01-02T15:04:05Z07:00"
+	"2006-01-02T15:04Z07:00", // W3C-DTF without seconds, used by dc:date
+	time.DateOnly,            // "2006-01-02"
 }
 
 // ParseRSSTime tries multiple layouts until one works
@@ -33,52 +41,293 @@ func ParseRSSTime(value string) (time.Time, error) {
 
 type RSSFeed struct {
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		Title       string     `xml:"title"`
+		AtomLinks   []atomLink `xml:"http://www.w3.org/2005/Atom link"` // matched first so atom:link doesn't overwrite Link
+		Link        string     `xml:"link"`
+		Description string     `xml:"description"`
+		Item        []RSSItem  `xml:"item"`
+
+		// Hints from the publisher on how often the feed should be fetched
+		TTL             string   `xml:"ttl"`
+		UpdatePeriod    string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+		SkipHours       []string `xml:"skipHours>hour"`
+		SkipDays        []string `xml:"skipDays>day"`
 	} `xml:"channel"`
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	GUID        string         `xml:"guid"`
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	Description string         `xml:"description"`
+	Content     string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string         `xml:"pubDate"`
+	Author      string         `xml:"author"`
+	Creator     string         `xml:"http://purl.org/dc/elements/1.1/ creator"` // merged into Author when parsed
+	Categories  []string       `xml:"category"`
+	Enclosures  []RSSEnclosure `xml:"enclosure"`
+	ITunesItem
+}
+
+// Podcast fields from the iTunes namespace
+type ITunesItem struct {
+	Duration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Episode  string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	Season   string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+}
+
+// ParseITunesDuration parses an itunes:duration given as seconds, MM:SS or HH:MM:SS
+func ParseITunesDuration(value string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid itunes duration '%v'", value)
+	}
+
+	var seconds float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid itunes duration '%v'", value)
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Media file attached to an item, such as a podcast episode
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// Get the name of the first element in an XML document
+func rootElement(body []byte, charset string) (xml.Name, error) {
+	decoder := newDecoder(body, charset)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// Unmarshal the body based on its format, other XML documents are rejected
+func parseFeed(contentType string, body []byte) (*RSSFeed, error) {
+	if isJSONFeed(contentType, body) {
+		return parseJSONFeed(body)
+	}
+
+	charset := contentTypeCharset(contentType)
+	root, err := rootElement(body, charset)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root.Local {
+	case "feed":
+		return parseAtom(body, charset)
+	case "RDF":
+		return parseRDF(body, charset)
+	case "rss":
+		var rss RSSFeed
+		err = newDecoder(body, charset).Decode(&rss)
+		if err != nil {
+			return nil, err
+		}
+		for idx, item := range rss.Channel.Item {
+			if item.Author == "" {
+				rss.Channel.Item[idx].Author = item.Creator
+			}
+		}
+		return &rss, nil
+	default:
+		return nil, fmt.Errorf("unsupported document type <%v>", root.Local)
+	}
+}
+
+// Used when FetchOptions.MaxBodySize is not set
+const DefaultMaxBodySize = 10 << 20
+
+// Longest a single request for a feed may take, including reading the body
+const FetchTimeout = 10 * time.Second
+
+// Options for a fetch. ETag and LastModified are cache validators from a previous response,
+// used to make a conditional request. MaxBodySize caps the size of the response body.
+type FetchOptions struct {
+	ETag         string
+	LastModified string
+	MaxBodySize  int64
+}
+
+// FetchResult holds the parsed feed along with the cache validators of the response.
+// Feed is nil when NotModified is set. FinalURL is the url the feed was fetched from after
+// following redirects, PermanentRedirect is set when every redirect was a 301 or 308.
+// StatusCode and Bytes describe the response for the fetch history.
+type FetchResult struct {
+	Feed              *RSSFeed
+	NotModified       bool
+	ETag              string
+	LastModified      string
+	FinalURL          string
+	PermanentRedirect bool
+	StatusCode        int
+	Bytes             int64
 }
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+// Identifier returns a key for the item that is stable across fetches of its feed.
+// The publisher's guid is preferred, falling back to the link and then to a hash of
+// the title and description for items that have neither.
+func (i RSSItem) Identifier() string {
+	if guid := strings.TrimSpace(i.GUID); guid != "" {
+		return guid
+	}
+	if link := strings.TrimSpace(i.Link); link != "" {
+		return link
+	}
+	sum := sha256.Sum256([]byte(i.Title + "\x00" + i.Description))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Prefix of content hashes, bumped whenever the fields covered by the hash change
+const contentHashVersion = "v2:"
+
+// ContentHash returns a digest of the item's content, used to detect edits by the publisher
+func (i RSSItem) ContentHash() string {
+	fields := []string{i.Title, i.Link, i.Description, i.Content, i.Author}
+	fields = append(fields, i.Categories...)
+	for _, enclosure := range i.Enclosures {
+		fields = append(fields, enclosure.URL)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+	return contentHashVersion + hex.EncodeToString(sum[:])
+}
+
+// IsCurrentContentHash reports whether a stored hash was made by this version of
+// ContentHash. Hashes in an older format differ even when the content hasn't changed.
+func IsCurrentContentHash(hash string) bool {
+	return strings.HasPrefix(hash, contentHashVersion)
+}
+
+func FetchFeed(ctx context.Context, feedURL string, opts FetchOptions) (*FetchResult, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error making request to '%v': %v", feedURL, err)
 	}
 	req.Header.Set("User-Agent", "gator")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+	if opts.ETag != "" {
+		req.Header.Set("If-None-Match", opts.ETag)
+	}
+	if opts.LastModified != "" {
+		req.Header.Set("If-Modified-Since", opts.LastModified)
+	}
 
+	// Track whether the feed has moved for good or only for this request
+	redirects, permanent := 0, true
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout: FetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			redirects++
+			status := req.Response.StatusCode
+			if status != http.StatusMovedPermanently && status != http.StatusPermanentRedirect {
+				permanent = false
+			}
+			return nil
+		},
 	}
 	res, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error getting response from '%v': %v", feedURL, err)
+		return nil, fmt.Errorf("error getting response from '%v': %w", feedURL, err)
 	}
+	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading body from '%v': %v", feedURL, err)
+	result := &FetchResult{
+		ETag:              res.Header.Get("ETag"),
+		LastModified:      res.Header.Get("Last-Modified"),
+		FinalURL:          res.Request.URL.String(),
+		PermanentRedirect: redirects > 0 && permanent,
+		StatusCode:        res.StatusCode,
 	}
 
-	var rss RSSFeed
-	err = xml.Unmarshal(body, &rss)
+	// Nothing changed since the last fetch, keep the validators we already have
+	if res.StatusCode == http.StatusNotModified {
+		result.NotModified = true
+		if result.ETag == "" {
+			result.ETag = opts.ETag
+		}
+		if result.LastModified == "" {
+			result.LastModified = opts.LastModified
+		}
+		return result, nil
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &HTTPError{
+			URL:        feedURL,
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	// Media files can't be feeds, don't bother downloading them
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	for _, prefix := range []string{"image/", "audio/", "video/", "font/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return nil, fmt.Errorf("%w '%v' from '%v'", ErrUnsupportedType, mediaType, feedURL)
+		}
+	}
+
+	maxBodySize := opts.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	if res.ContentLength > maxBodySize {
+		return nil, fmt.Errorf("%w: '%v' is %v bytes, the limit is %v", ErrTooLarge, feedURL, res.ContentLength, maxBodySize)
+	}
+
+	// Read one byte past the limit to tell a body of exactly the limit from a larger one
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBodySize+1))
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling data from %v: %v", feedURL, err)
+		return nil, fmt.Errorf("error reading body from '%v': %w", feedURL, err)
+	}
+	if int64(len(body)) > maxBodySize {
+		return nil, fmt.Errorf("%w: '%v' is over the limit of %v bytes", ErrTooLarge, feedURL, maxBodySize)
+	}
+
+	// Pages are searched for the feeds they advertise so callers can offer them instead.
+	// The body is parsed first since some servers send feeds labelled as text/html.
+	rss, err := parseFeed(res.Header.Get("Content-Type"), body)
+	if err != nil && isHTML(res.Header.Get("Content-Type"), body) {
+		return nil, &HTMLPageError{
+			URL:   feedURL,
+			Feeds: discoverFeeds(res.Request.URL.String(), body),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%w from %v: %v", ErrUnparsable, feedURL, err)
 	}
 	rss.Channel.Title = html.UnescapeString(rss.Channel.Title)
 	rss.Channel.Description = html.UnescapeString(rss.Channel.Description)
 
 	for idx := range rss.Channel.Item {
-		rss.Channel.Item[idx].Title = html.UnescapeString(rss.Channel.Item[idx].Title)
-		rss.Channel.Item[idx].Description = html.UnescapeString(rss.Channel.Item[idx].Description)
+		item := &rss.Channel.Item[idx]
+		item.Title = html.UnescapeString(item.Title)
+		item.Description = html.UnescapeString(item.Description)
+		item.Author = strings.TrimSpace(item.Author)
+		for c := range item.Categories {
+			item.Categories[c] = strings.TrimSpace(html.UnescapeString(item.Categories[c]))
+		}
 	}
 
-	return &rss, nil
+	result.Feed = rss
+	result.Bytes = int64(len(body))
+	return result, nil
 }
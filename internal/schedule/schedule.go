@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of recent posts used to estimate how often a feed posts
+const postingSample = 20
+
+// Policy bounds the interval between fetches of a single feed, and the wait before
+// retrying one that failed
+type Policy struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Hints published by a feed about when it should be fetched. Skipped hours are in UTC as
+// the RSS spec defines them in GMT.
+type Hints struct {
+	TTL          time.Duration
+	UpdatePeriod time.Duration
+	SkipHours    []int
+	SkipDays     []time.Weekday
+}
+
+// ParseHints converts the raw RSS <ttl>, sy:updatePeriod, sy:updateFrequency, <skipHours>
+// and <skipDays> values into Hints. Values that can't be parsed are ignored.
+func ParseHints(ttl, updatePeriod, updateFrequency string, skipHours, skipDays []string) Hints {
+	var hints Hints
+
+	// ttl is given in minutes
+	if minutes, err := strconv.Atoi(strings.TrimSpace(ttl)); err == nil && minutes > 0 {
+		hints.TTL = time.Duration(minutes) * time.Minute
+	}
+
+	// The feed updates updateFrequency times per updatePeriod, which defaults to daily
+	if updatePeriod != "" || updateFrequency != "" {
+		period := map[string]time.Duration{
+			"hourly":  time.Hour,
+			"daily":   24 * time.Hour,
+			"weekly":  7 * 24 * time.Hour,
+			"monthly": 30 * 24 * time.Hour,
+			"yearly":  365 * 24 * time.Hour,
+		}[strings.ToLower(strings.TrimSpace(updatePeriod))]
+		if period == 0 {
+			period = 24 * time.Hour
+		}
+		frequency, err := strconv.Atoi(strings.TrimSpace(updateFrequency))
+		if err != nil || frequency <= 0 {
+			frequency = 1
+		}
+		hints.UpdatePeriod = period / time.Duration(frequency)
+	}
+
+	for _, value := range skipHours {
+		// Some feeds use 24 for midnight
+		if hour, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && hour >= 0 && hour <= 24 {
+			hints.SkipHours = append(hints.SkipHours, hour%24)
+		}
+	}
+	for _, value := range skipDays {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(strings.TrimSpace(value), day.String()) {
+				hints.SkipDays = append(hints.SkipDays, day)
+			}
+		}
+	}
+	return hints
+}
+
+// PostingInterval estimates how often a feed posts from the publish times of its items,
+// using the median gap between the most recent ones. Zero is returned when there are too
+// few posts to tell.
+func PostingInterval(published []time.Time) time.Duration {
+	times := slices.Clone(published)
+	slices.SortFunc(times, func(a, b time.Time) int { return b.Compare(a) })
+	if len(times) > postingSample {
+		times = times[:postingSample]
+	}
+
+	var gaps []time.Duration
+	for i := 1; i < len(times); i++ {
+		if gap := times[i-1].Sub(times[i]); gap > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		return 0
+	}
+	slices.Sort(gaps)
+	return gaps[len(gaps)/2]
+}
+
+// Interval picks the time to wait before fetching a feed again. Feeds are fetched about
+// twice per posting interval, but never more often than the publisher's ttl or update
+// period asks for. The result always stays within the policy's bounds.
+func (p Policy) Interval(posting time.Duration, hints Hints) time.Duration {
+	interval := p.MaxInterval
+	if posting > 0 {
+		interval = posting / 2
+	}
+	return p.Clamp(max(interval, hints.TTL, hints.UpdatePeriod))
+}
+
+// Clamp keeps an interval within the policy's bounds
+func (p Policy) Clamp(interval time.Duration) time.Duration {
+	return min(max(interval, p.MinInterval), p.MaxInterval)
+}
+
+// Backoff picks the wait before retrying a feed after its nth consecutive failure. The
+// wait starts at the minimum interval and doubles with every failure, up to half of it
+// again is added at random so feeds that failed together don't retry in lockstep. The
+// server's Retry-After is honored as a lower bound and the result never exceeds the
+// maximum backoff.
+func (p Policy) Backoff(failures int, retryAfter time.Duration) time.Duration {
+	delay := max(p.MinInterval, time.Second)
+	for i := 1; i < failures && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if half := delay / 2; half > 0 {
+		delay += rand.N(half)
+	}
+	return min(max(delay, retryAfter), p.MaxBackoff)
+}
+
+// Next returns the first time at or after from that isn't in one of the feed's skipped
+// hours or days
+func Next(from time.Time, hints Hints) time.Time {
+	next := from
+	// A week of hours covers every combination of skipped hours and days
+	for range 7 * 24 {
+		utc := next.UTC()
+		if !slices.Contains(hints.SkipDays, utc.Weekday()) && !slices.Contains(hints.SkipHours, utc.Hour()) {
+			return next
+		}
+		next = utc.Truncate(time.Hour).Add(time.Hour).In(from.Location())
+	}
+	// Every hour is skipped, ignore the hints rather than never fetching the feed
+	return from
+}
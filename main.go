@@ -34,8 +34,9 @@ func main() {
 
 	// Store the config state as context
 	context := cli.State{
-		DB:  dbQueries,
-		Cfg: &cfg,
+		DB:   dbQueries,
+		Conn: db,
+		Cfg:  &cfg,
 	}
 
 	// Create a command registry and register relevant commands
@@ -47,12 +48,18 @@ func main() {
 	commands.Register("reset", cli.HandlerReset)
 	commands.Register("users", cli.HandlerUsers)
 	commands.Register("agg", cli.HandlerAgg)
+	commands.Register("fetch", cli.HandlerFetch)
 	commands.Register("addfeed", cli.MiddlewareLoggedIn(cli.HandlerAddFeed))
 	commands.Register("feeds", cli.HandlerFeeds)
+	commands.Register("feed", cli.HandlerFeed)
 	commands.Register("follow", cli.MiddlewareLoggedIn(cli.HandlerFollow))
 	commands.Register("following", cli.MiddlewareLoggedIn(cli.HandlerFollowing))
 	commands.Register("unfollow", cli.MiddlewareLoggedIn(cli.HandlerUnfollow))
 	commands.Register("browse", cli.MiddlewareLoggedIn(cli.HandlerBrowse))
+	commands.Register("download", cli.MiddlewareLoggedIn(cli.HandlerDownload))
+	commands.Register("import", cli.MiddlewareLoggedIn(cli.HandlerImport))
+	commands.Register("export", cli.MiddlewareLoggedIn(cli.HandlerExport))
+	commands.Register("folder", cli.MiddlewareLoggedIn(cli.HandlerFolder))
 
 	// Create a command from the user provided args and run it with given context
 	command := cli.Command{
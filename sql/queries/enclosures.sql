@@ -0,0 +1,29 @@
+-- name: UpsertEnclosure :exec
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, type, length, duration_seconds, episode, season)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (post_id, url) DO UPDATE
+SET type = EXCLUDED.type,
+    length = EXCLUDED.length,
+    duration_seconds = EXCLUDED.duration_seconds,
+    episode = EXCLUDED.episode,
+    season = EXCLUDED.season,
+    updated_at = EXCLUDED.updated_at;
+
+-- name: GetPendingEnclosuresForUser :many
+SELECT enclosures.*, posts.title AS post_title, feeds.name AS feed_name
+FROM enclosures
+INNER JOIN posts
+    ON posts.id = enclosures.post_id
+INNER JOIN feeds
+    ON feeds.id = posts.feed_id
+INNER JOIN feed_follows
+    ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+  AND enclosures.downloaded_at IS NULL
+ORDER BY COALESCE(posts.published_at, posts.created_at) DESC
+LIMIT $2;
+
+-- name: MarkEnclosureDownloaded :exec
+UPDATE enclosures
+SET file_path = $2, downloaded_at = NOW(), updated_at = NOW()
+WHERE id = $1;
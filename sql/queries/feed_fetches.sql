@@ -0,0 +1,18 @@
+-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches (id, feed_id, started_at, duration_ms, status_code, bytes, items, inserted, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+
+-- name: GetFeedFetches :many
+SELECT *
+FROM feed_fetches
+WHERE feed_id = $1
+ORDER BY started_at DESC
+LIMIT $2;
+
+-- name: GetFeedFetchStats :one
+SELECT
+    COUNT(*) AS attempts,
+    COUNT(*) FILTER (WHERE error IS NULL) AS successes,
+    COALESCE(AVG(duration_ms), 0)::float8 AS average_duration_ms
+FROM feed_fetches
+WHERE feed_id = $1;
@@ -12,7 +12,7 @@ INNER JOIN feeds
     ON inserted.feed_id = feeds.id;
 
 -- name: GetFeedFollowsForUser :many
-SELECT feed_follows.*, users.name AS user_name, feeds.name AS feed_name
+SELECT feed_follows.*, users.name AS user_name, feeds.name AS feed_name, feeds.url AS feed_url, feeds.site_url AS feed_site_url
 FROM feed_follows
 INNER JOIN users
     ON feed_follows.user_id = users.id
@@ -25,4 +25,23 @@ DELETE FROM feed_follows
 USING feeds
 WHERE feed_follows.feed_id = feeds.id
   AND feed_follows.user_id = $1
-  AND feeds.url = $2;
\ No newline at end of file
+  AND feeds.url = $2;
+
+-- name: SetFeedFollowFolder :execrows
+UPDATE feed_follows
+SET folder = $3, updated_at = NOW()
+FROM feeds
+WHERE feed_follows.feed_id = feeds.id
+  AND feed_follows.user_id = $1
+  AND feeds.url = $2;
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = sqlc.arg(to_feed_id)::uuid, updated_at = NOW()
+WHERE feed_id = sqlc.arg(from_feed_id)::uuid
+  AND NOT EXISTS (
+    SELECT 1
+    FROM feed_follows AS existing
+    WHERE existing.feed_id = sqlc.arg(to_feed_id)::uuid
+      AND existing.user_id = feed_follows.user_id
+  );
@@ -1,17 +1,32 @@
 -- name: CreateFeed :one
-INSERT INTO feeds(id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds(id, created_at, updated_at, name, url, user_id, site_url)
 VALUES(
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: ClaimNextFeed :one
+UPDATE feeds
+SET last_fetched_at = NOW(), next_fetch_at = sqlc.arg(lease_until)::timestamp, updated_at = NOW()
+WHERE id = (
+    SELECT id
+    FROM feeds
+    WHERE disabled_at IS NULL
+      AND (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg(due_at)::timestamp)
+    ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
 )
 RETURNING *;
 
 -- name: GetFeeds :many
-SELECT feeds.name, feeds.url, users.name as user_name
+SELECT feeds.name, feeds.url, feeds.last_error, feeds.last_error_at, feeds.failure_count, feeds.disabled_at, feeds.disabled_reason, users.name as user_name
 FROM feeds
 LEFT JOIN users
 ON feeds.user_id = users.id;
@@ -20,13 +35,68 @@ ON feeds.user_id = users.id;
 SELECT * FROM feeds
 WHERE url = $1;
 
--- name: MarkFeedFetched :exec
+-- name: MarkFeedFailed :one
+UPDATE feeds
+SET last_fetched_at = NOW(), last_error = $2, last_error_at = NOW(), failure_count = failure_count + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING failure_count;
+
+-- name: MarkFeedSucceeded :exec
+UPDATE feeds
+SET last_fetched_at = NOW(), failure_count = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateFeedCacheHeaders :exec
 UPDATE feeds
-SET last_fetched_at = NOW(), updated_at = NOW()
+SET etag = $2, last_modified = $3, updated_at = NOW()
 WHERE id = $1;
 
--- name: GetNextFeedToFetch :one
-SELECT *
+-- name: SetFeedSchedule :exec
+UPDATE feeds
+SET next_fetch_at = $2, fetch_interval_seconds = $3, skip_hours = $4, skip_days = $5, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateFeedSiteURL :exec
+UPDATE feeds
+SET site_url = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET url = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: DisableFeed :exec
+UPDATE feeds
+SET disabled_at = NOW(), disabled_reason = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetDisabledFeeds :many
+SELECT name, url, disabled_at, disabled_reason, last_error
+FROM feeds
+WHERE disabled_at IS NOT NULL
+ORDER BY disabled_at DESC;
+
+-- name: ReenableFeed :execrows
+UPDATE feeds
+SET url = COALESCE(sqlc.narg(new_url)::text, url),
+    disabled_at = NULL,
+    disabled_reason = NULL,
+    failure_count = 0,
+    last_fetched_at = NULL,
+    next_fetch_at = NULL,
+    etag = NULL,
+    last_modified = NULL,
+    updated_at = NOW()
+WHERE url = sqlc.arg(url)
+  AND disabled_at IS NOT NULL;
+
+-- name: CountOverdueFeeds :one
+SELECT COUNT(*)
 FROM feeds
-ORDER BY last_fetched_at NULLS FIRST, last_fetched_at ASC
-LIMIT 1;
\ No newline at end of file
+WHERE disabled_at IS NULL
+  AND (last_fetched_at IS NULL OR last_fetched_at < NOW() - make_interval(secs => sqlc.arg(max_interval_seconds)::float8));
@@ -1,15 +1,105 @@
--- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+-- name: UpsertPost :one
+INSERT INTO posts (
+    id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash,
+    content, author, enclosure_url, enclosure_type, enclosure_length
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = EXCLUDED.published_at,
+    content_hash = EXCLUDED.content_hash,
+    content = EXCLUDED.content,
+    author = EXCLUDED.author,
+    enclosure_url = EXCLUDED.enclosure_url,
+    enclosure_type = EXCLUDED.enclosure_type,
+    enclosure_length = EXCLUDED.enclosure_length,
+    updated_at = EXCLUDED.updated_at
+WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
 RETURNING *;
 
+-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (id, created_at, post_id, title, description, content_hash)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: CreatePostCategory :exec
+INSERT INTO post_categories (post_id, name)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: DeletePostCategories :exec
+DELETE FROM post_categories
+WHERE post_id = $1;
+
+-- name: GetPostCategories :many
+SELECT name
+FROM post_categories
+WHERE post_id = $1
+ORDER BY name;
+
+-- name: GetPostContentHash :one
+SELECT content_hash, updated_at
+FROM posts
+WHERE feed_id = $1
+  AND guid = $2;
+
 -- name: GetPostsForUser :many
-SELECT posts.*
+SELECT posts.*, (post_views.viewed_at IS NOT NULL AND posts.updated_at > post_views.viewed_at)::boolean AS updated_since_seen
 FROM posts
 INNER JOIN feed_follows
     ON feed_follows.feed_id = posts.feed_id
 INNER JOIN users
     ON users.id = feed_follows.user_id
-WHERE users.id = $1
-ORDER BY posts.published_at DESC
-LIMIT $2;
\ No newline at end of file
+LEFT JOIN post_views
+    ON post_views.post_id = posts.id
+    AND post_views.user_id = users.id
+WHERE users.id = sqlc.arg(id)
+  AND (
+    sqlc.narg(category)::text IS NULL
+    OR EXISTS (
+        SELECT 1
+        FROM post_categories
+        WHERE post_categories.post_id = posts.id
+          AND post_categories.name = sqlc.narg(category)::text
+    )
+  )
+ORDER BY COALESCE(posts.published_at, posts.created_at) DESC
+LIMIT sqlc.arg('limit');
+
+-- name: MarkPostViewed :exec
+INSERT INTO post_views (user_id, post_id, viewed_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET viewed_at = EXCLUDED.viewed_at;
+
+-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = sqlc.arg(to_feed_id)::uuid
+WHERE feed_id = sqlc.arg(from_feed_id)::uuid
+  AND NOT EXISTS (
+    SELECT 1
+    FROM posts AS existing
+    WHERE existing.feed_id = sqlc.arg(to_feed_id)::uuid
+      AND existing.guid = posts.guid
+  );
+
+-- name: RekeyLegacyPost :exec
+UPDATE posts
+SET guid = sqlc.arg(guid)
+WHERE feed_id = sqlc.arg(feed_id)
+  AND guid = 'legacy:' || sqlc.arg(url)::text
+  AND NOT EXISTS (
+    SELECT 1
+    FROM posts AS existing
+    WHERE existing.feed_id = sqlc.arg(feed_id)
+      AND existing.guid = sqlc.arg(guid)
+  );
+
+-- name: GetRecentPublishTimes :many
+SELECT published_at
+FROM posts
+WHERE feed_id = $1
+  AND published_at IS NOT NULL
+ORDER BY published_at DESC
+LIMIT $2;
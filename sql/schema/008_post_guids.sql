@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN guid TEXT;
+
+-- Existing posts were deduplicated by url, but the guid of their item usually differs from
+-- it. They are marked as legacy and re-keyed to the item's identifier the next time the
+-- item is fetched, see RekeyLegacyPost.
+UPDATE posts
+SET guid = 'legacy:' || COALESCE(url, id::TEXT);
+
+ALTER TABLE posts
+ALTER COLUMN guid SET NOT NULL,
+DROP CONSTRAINT posts_url_key,
+ADD CONSTRAINT posts_feed_id_guid_key UNIQUE (feed_id, guid);
+
+-- +goose Down
+-- Feeds may now share posts with the same url, keep the oldest so the url is unique again
+DELETE FROM posts
+USING posts AS older
+WHERE posts.url = older.url
+  AND (older.created_at, older.id) < (posts.created_at, posts.id);
+
+ALTER TABLE posts
+DROP CONSTRAINT posts_feed_id_guid_key,
+ADD CONSTRAINT posts_url_key UNIQUE (url),
+DROP COLUMN guid;
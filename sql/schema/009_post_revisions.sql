@@ -0,0 +1,38 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN content_hash TEXT;
+
+CREATE TABLE post_revisions(
+    id UUID PRIMARY KEY, -- UUID 
+    created_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL,
+    title TEXT,
+    description TEXT,
+    content_hash TEXT NOT NULL,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id)
+        REFERENCES posts(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE post_views(
+    user_id UUID NOT NULL,
+    post_id UUID NOT NULL,
+    viewed_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id)
+        REFERENCES posts(id)
+        ON DELETE CASCADE,
+    PRIMARY KEY(user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE post_views;
+DROP TABLE post_revisions;
+
+ALTER TABLE posts
+DROP COLUMN content_hash;
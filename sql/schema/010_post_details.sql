@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN content TEXT,
+ADD COLUMN author TEXT,
+ADD COLUMN enclosure_url TEXT,
+ADD COLUMN enclosure_type TEXT,
+ADD COLUMN enclosure_length BIGINT;
+
+CREATE TABLE post_categories(
+    post_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id)
+        REFERENCES posts(id)
+        ON DELETE CASCADE,
+    PRIMARY KEY(post_id, name)
+);
+
+-- +goose Down
+DROP TABLE post_categories;
+
+ALTER TABLE posts
+DROP COLUMN content,
+DROP COLUMN author,
+DROP COLUMN enclosure_url,
+DROP COLUMN enclosure_type,
+DROP COLUMN enclosure_length;
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE enclosures(
+    id UUID PRIMARY KEY, -- UUID 
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL,
+    url TEXT NOT NULL,
+    type TEXT,
+    length BIGINT,
+    duration_seconds INTEGER,
+    episode INTEGER,
+    season INTEGER,
+    file_path TEXT,
+    downloaded_at TIMESTAMP,
+    CONSTRAINT fk_post_id
+        FOREIGN KEY (post_id)
+        REFERENCES posts(id)
+        ON DELETE CASCADE,
+    UNIQUE(post_id, url)
+);
+
+-- Queue the enclosures already captured on posts for download
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, type, length)
+SELECT gen_random_uuid(), NOW(), NOW(), id, enclosure_url, enclosure_type, enclosure_length
+FROM posts
+WHERE enclosure_url IS NOT NULL;
+
+-- +goose Down
+DROP TABLE enclosures;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN next_fetch_at TIMESTAMP NULL,
+ADD COLUMN fetch_interval_seconds INTEGER NULL;
+
+CREATE INDEX feeds_next_fetch_at_idx ON feeds(next_fetch_at);
+
+-- +goose Down
+DROP INDEX feeds_next_fetch_at_idx;
+
+ALTER TABLE feeds
+DROP COLUMN fetch_interval_seconds,
+DROP COLUMN next_fetch_at;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE feed_fetches(
+    id UUID PRIMARY KEY, -- UUID
+    feed_id UUID NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    status_code INTEGER,
+    bytes BIGINT,
+    items INTEGER NOT NULL DEFAULT 0,
+    inserted INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    CONSTRAINT fk_feed_id
+        FOREIGN KEY (feed_id)
+        REFERENCES feeds(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX feed_fetches_feed_id_started_at_idx ON feed_fetches(feed_id, started_at DESC);
+
+-- +goose Down
+DROP TABLE feed_fetches;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Hours (UTC) and days (0 is Sunday) the publisher asked not to be fetched in, kept so
+-- fetches that return 304 or fail are still scheduled around them
+ALTER TABLE feeds
+ADD COLUMN skip_hours INTEGER[] NULL,
+ADD COLUMN skip_days INTEGER[] NULL;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN skip_days,
+DROP COLUMN skip_hours;